@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// AtomicFile is written to a temporary file next to its path, which replaces
+// the path only on Commit. A crash or a full disk mid-write leaves the old
+// file untouched, readers never see a partial file.
+type AtomicFile struct {
+	*os.File
+	path      string
+	committed bool
+}
+
+// createAtomic starts writing path with the given permissions.
+func createAtomic(path string, perm os.FileMode) (*AtomicFile, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &AtomicFile{File: tmp, path: path}, nil
+}
+
+// Commit syncs the temporary file to disk and renames it over the path.
+func (f *AtomicFile) Commit() error {
+	if err := f.File.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	f.committed = true
+	syncDir(filepath.Dir(f.path))
+	return nil
+}
+
+// Close discards the temporary file unless it was committed, so a deferred
+// Close cleans up after errors.
+func (f *AtomicFile) Close() error {
+	if f.committed {
+		return nil
+	}
+	f.committed = true
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// syncDir makes a rename in dir durable. Not every platform can sync a
+// directory, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// writeFileAtomic is os.WriteFile through an AtomicFile.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := createAtomic(path, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
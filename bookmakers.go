@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Bookmaker is a catalog entry mapping the names and OddsPortal provider ids
+// a bookmaker shows up with to one canonical id.
+type Bookmaker struct {
+	ID          string   `json:"id"`   // pinnacle
+	Name        string   `json:"name"` // Pinnacle
+	Aliases     []string `json:"aliases,omitempty"`
+	ProviderIDs []int    `json:"provider_ids,omitempty"` // OddsPortal provider ids, e.g. MaxOddsProviderID
+	Sharp       bool     `json:"sharp"`
+	Exchange    bool     `json:"exchange"`
+	Region      string   `json:"region,omitempty"` // global, eu, uk, it...
+}
+
+// BookmakerCatalog resolves bookmakers by normalized name or provider id.
+type BookmakerCatalog struct {
+	bookmakers []Bookmaker
+	byName     map[string]*Bookmaker
+	byProvider map[int]*Bookmaker
+}
+
+var DEFAULT_BOOKMAKERS = []Bookmaker{
+	{ID: "pinnacle", Name: "Pinnacle", Aliases: []string{"Pinnacle Sports"}, ProviderIDs: []int{18}, Sharp: true, Region: "global"},
+	{ID: "bet365", Name: "bet365", ProviderIDs: []int{16}, Region: "global"},
+	{ID: "betfair", Name: "Betfair Exchange", Aliases: []string{"Betfair"}, ProviderIDs: []int{44}, Sharp: true, Exchange: true, Region: "global"},
+	{ID: "betfair-sportsbook", Name: "Betfair Sportsbook", Region: "uk"},
+	{ID: "unibet", Name: "Unibet", ProviderIDs: []int{5}, Region: "eu"},
+	{ID: "williamhill", Name: "William Hill", ProviderIDs: []int{15}, Region: "uk"},
+	{ID: "bwin", Name: "bwin", ProviderIDs: []int{2}, Region: "eu"},
+	{ID: "1xbet", Name: "1xBet", ProviderIDs: []int{417}, Region: "global"},
+	{ID: "betway", Name: "Betway", Region: "global"},
+	{ID: "marathonbet", Name: "Marathonbet", Aliases: []string{"Marathon Bet"}, Region: "global"},
+	{ID: "betsson", Name: "Betsson", Region: "eu"},
+	{ID: "matchbook", Name: "Matchbook", Sharp: true, Exchange: true, Region: "uk"},
+	{ID: "betdaq", Name: "Betdaq", Exchange: true, Region: "uk"},
+	{ID: "smarkets", Name: "Smarkets", Exchange: true, Region: "uk"},
+}
+
+var bookmakers = newBookmakerCatalog(DEFAULT_BOOKMAKERS)
+
+// Strips regional domains and separators, e.g. 'Bet365.it' and 'bet-365' to 'bet365'
+var bookmakerDomain = regexp.MustCompile(`\.(com|net|[a-z]{2})$`)
+var bookmakerSeparators = strings.NewReplacer(" ", "", "-", "", "_", "", ".", "")
+
+func normalizeBookmaker(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = bookmakerDomain.ReplaceAllString(name, "")
+	return bookmakerSeparators.Replace(name)
+}
+
+func newBookmakerCatalog(list []Bookmaker) *BookmakerCatalog {
+	c := &BookmakerCatalog{
+		bookmakers: list,
+		byName:     make(map[string]*Bookmaker),
+		byProvider: make(map[int]*Bookmaker),
+	}
+	// Earlier entries win, so entries from a file override the defaults
+	for i := len(list) - 1; i >= 0; i-- {
+		b := &list[i]
+		for _, n := range append([]string{b.ID, b.Name}, b.Aliases...) {
+			c.byName[normalizeBookmaker(n)] = b
+		}
+		for _, id := range b.ProviderIDs {
+			c.byProvider[id] = b
+		}
+	}
+	return c
+}
+
+// loadBookmakers reads a JSON array of Bookmaker from path. The entries from
+// the file are checked before the built-in ones.
+func loadBookmakers(path string) (*BookmakerCatalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Bookmaker
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("error unmarshaling bookmakers: %v", err)
+	}
+	for _, b := range list {
+		if b.ID == "" {
+			return nil, fmt.Errorf("bookmaker %q has no id", b.Name)
+		}
+	}
+
+	return newBookmakerCatalog(append(list, DEFAULT_BOOKMAKERS...)), nil
+}
+
+// Lookup finds a bookmaker by its display name, nil if it isn't in the catalog.
+func (c *BookmakerCatalog) Lookup(name string) *Bookmaker {
+	return c.byName[normalizeBookmaker(name)]
+}
+
+// Provider finds a bookmaker by its OddsPortal provider id.
+func (c *BookmakerCatalog) Provider(id int) *Bookmaker {
+	return c.byProvider[id]
+}
+
+// CanonicalID returns the catalog id of a bookmaker, or the normalized name
+// for bookmakers not in the catalog.
+func (c *BookmakerCatalog) CanonicalID(name string) string {
+	if b := c.Lookup(name); b != nil {
+		return b.ID
+	}
+	return normalizeBookmaker(name)
+}
+
+// Type is 'exchange', 'sharp' or 'soft'.
+func (b *Bookmaker) Type() string {
+	switch {
+	case b == nil:
+		return ""
+	case b.Exchange:
+		return "exchange"
+	case b.Sharp:
+		return "sharp"
+	}
+	return "soft"
+}
+
+func (b *Bookmaker) region() string {
+	if b == nil {
+		return ""
+	}
+	return b.Region
+}
+
+// resolveBookmakers fills the canonical bookmaker ids of the odds rows and
+// the names of the providers with the best odds.
+func resolveBookmakers(m *Match) {
+	for market := range m.OddsData {
+		for i := range m.OddsData[market] {
+			o := &m.OddsData[market][i]
+			o.BookmakerID = bookmakers.CanonicalID(o.Bookmaker)
+		}
+	}
+	for i := range m.Odds {
+		if b := bookmakers.Provider(m.Odds[i].MaxOddsProviderID); b != nil {
+			m.Odds[i].MaxOddsBookmaker = b.ID
+		}
+	}
+}
+
+// BookmakerFilter selects the bookmakers to scrape by canonical id. Rows of
+// excluded bookmakers are skipped in the browser before their cells are read.
+type BookmakerFilter struct {
+	include map[string]bool
+	exclude map[string]bool
+	top     int // keep the N bookmakers covering the most markets of a match, 0 for all
+}
+
+var bookmakerFilter = &BookmakerFilter{}
+
+// parseBookmakerFilter reads a comma separated list of bookmakers or a file
+// with one bookmaker per line, a leading '-' excludes the bookmaker. With
+// strict and no included bookmakers BOOKMAKERS_TO_SCRAPE are included.
+func parseBookmakerFilter(spec string, strict bool, top int) (*BookmakerFilter, error) {
+	f := &BookmakerFilter{
+		include: make(map[string]bool),
+		exclude: make(map[string]bool),
+		top:     top,
+	}
+
+	entries := strings.Split(spec, ",")
+	if spec != "" && !strings.Contains(spec, ",") {
+		if data, err := os.ReadFile(spec); err == nil {
+			entries = strings.Split(string(data), "\n")
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading bookmaker file %s: %v", spec, err)
+		}
+	}
+
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" || strings.HasPrefix(e, "#") {
+			continue
+		}
+		if name, ok := strings.CutPrefix(e, "-"); ok {
+			f.exclude[bookmakers.CanonicalID(name)] = true
+		} else {
+			f.include[bookmakers.CanonicalID(e)] = true
+		}
+	}
+
+	if strict && len(f.include) == 0 {
+		for _, id := range BOOKMAKERS_TO_SCRAPE {
+			f.include[id] = true
+		}
+	}
+	if top < 0 {
+		return nil, fmt.Errorf("top bookmakers must be positive, got %d", top)
+	}
+	return f, nil
+}
+
+// Wants reports whether rows of the bookmaker should be kept.
+func (f *BookmakerFilter) Wants(name string) bool {
+	id := bookmakers.CanonicalID(name)
+	if f.exclude[id] {
+		return false
+	}
+	return len(f.include) == 0 || f.include[id]
+}
+
+// names returns the normalized names of every bookmaker in ids, for matching
+// the rows in the browser without the catalog.
+func (f *BookmakerFilter) names(ids map[string]bool) []string {
+	names := []string{}
+	for id := range ids {
+		names = append(names, normalizeBookmaker(id))
+		if b := bookmakers.Lookup(id); b != nil {
+			names = append(names, normalizeBookmaker(b.Name))
+			for _, a := range b.Aliases {
+				names = append(names, normalizeBookmaker(a))
+			}
+		}
+	}
+	return names
+}
+
+// js returns the filter as the JSON object used by ODDS_TABLE_JS.
+func (f *BookmakerFilter) js() string {
+	data, _ := json.Marshal(map[string][]string{
+		"include": f.names(f.include),
+		"exclude": f.names(f.exclude),
+	})
+	return string(data)
+}
+
+// Top drops the rows of every bookmaker not among the top N by the number of
+// markets they have odds for. Ties go to the bookmaker listed first by name.
+func (f *BookmakerFilter) Top(oddsData map[string][]OddRow) {
+	if f.top == 0 {
+		return
+	}
+
+	coverage := make(map[string]int)
+	for _, rows := range oddsData {
+		seen := make(map[string]bool)
+		for _, o := range rows {
+			if !seen[o.BookmakerID] {
+				seen[o.BookmakerID] = true
+				coverage[o.BookmakerID]++
+			}
+		}
+	}
+	if len(coverage) <= f.top {
+		return
+	}
+
+	ids := make([]string, 0, len(coverage))
+	for id := range coverage {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if coverage[ids[i]] != coverage[ids[j]] {
+			return coverage[ids[i]] > coverage[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	keep := make(map[string]bool)
+	for _, id := range ids[:f.top] {
+		keep[id] = true
+	}
+
+	for market, rows := range oddsData {
+		kept := rows[:0]
+		for _, o := range rows {
+			if keep[o.BookmakerID] {
+				kept = append(kept, o)
+			}
+		}
+		oddsData[market] = kept
+	}
+	printDebug(fmt.Sprintf("Kept the top %d bookmakers by market coverage: %v", f.top, ids[:f.top]))
+}
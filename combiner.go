@@ -1,202 +1,204 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
 func combine() {
-	path := filepath.FromSlash(filePath)
-	files, err := os.ReadDir(path)
+	root, files, err := findInputs(filePath, recursive)
 	if err != nil {
-		log.Fatalf("Error reading directory: %v", err)
+		log.Fatalf("Error finding files to combine: %v", err)
 	}
 
-	var allMatches []Match
-
-	for _, f := range files {
-		if !f.Type().IsRegular() || !strings.HasSuffix(f.Name(), ".json") {
-			continue
+	inputs := combineInputs(files)
+	printLog(fmt.Sprintf("Combining %d files from %s", len(inputs), root))
+	if outputFormat == "ndjson" {
+		if err := processNDJSONFile(root, inputs); err != nil {
+			log.Printf("Error processing NDJSON file: %v", err)
 		}
+		return
+	}
 
-		fp := filepath.Join(path, f.Name())
-
-		file, err := os.ReadFile(fp)
-		if err != nil {
-			log.Printf("error reading file: %v", err)
-			continue
-		}
+	merger := newMatchMerger()
 
+	for _, fp := range inputs {
 		fmt.Printf("CHECKING AND MERGING: %v \n", fp)
-		var matches []Match
-		if err := json.Unmarshal(file, &matches); err != nil {
-			log.Printf("error unmarshalling JSON: %v", err)
-			continue
+		err := readMatches(fp, func(m *Match) error {
+			tagSource(m, root, fp)
+			merger.Add(m, fp)
+			return nil
+		})
+		if err != nil {
+			log.Printf("error reading file %s: %v", fp, err)
 		}
+	}
 
-		allMatches = append(allMatches, matches...)
+	// Filter the merged matches, a stale record must not decide for its match
+	allMatches := slices.DeleteFunc(merger.Matches(), func(m Match) bool {
+		return !combineFilter.Keep(&m)
+	})
+	if err := merger.Report(); err != nil {
+		log.Printf("Error writing conflict report: %v", err)
 	}
 
-	if outputAsCSV {
+	switch outputFormat {
+	case "csv":
 		if err := processCSVFile(allMatches); err != nil {
 			log.Printf("Error processing CSV file: %v", err)
 		}
-	} else {
+	case "wide":
+		if err := processWideCSVFile(allMatches); err != nil {
+			log.Printf("Error processing wide CSV file: %v", err)
+		}
+	case "xlsx":
+		if err := processXLSXFile(allMatches); err != nil {
+			log.Printf("Error processing XLSX file: %v", err)
+		}
+	case "parquet":
+		if err := processParquetFile(allMatches); err != nil {
+			log.Printf("Error processing Parquet file: %v", err)
+		}
+	default:
 		if err := processJSONFile(allMatches); err != nil {
 			log.Printf("Error processing JSON file: %v", err)
 		}
 	}
 }
 
-func processCSVFile(matches []Match) error {
-	var csvRows []CSVMatch
+// combineInputs picks the JSON and NDJSON files to combine, leaving out the
+// combined files of an earlier run. A page file and its NDJSON shard are both
+// read, the merge keeps the shard's scraped odds and the page's other matches.
+func combineInputs(files []string) []string {
+	outputs := []string{filepath.Clean(outputFileName(".json")), filepath.Clean(outputFileName(".ndjson"))}
 
-	for _, match := range matches {
-		for marketType, marketData := range match.OddsData {
-			for _, lineData := range marketData {
-				for _, odd := range lineData.OddsData {
-					csvRow := CSVMatch{
-						ID:                  match.ID,
-						URL:                 match.URL,
-						HomeName:            retroTeamId(match.HomeName),
-						AwayName:            retroTeamId(match.AwayName),
-						Name:                match.Name,
-						EventStageName:      match.EventStageName,
-						TournamentStageName: match.TournamentStageName,
-						TournamentName:      match.TournamentName,
-						Date:                parseMatchDate(int64(match.DateStartBase)),
-						DateStartTimestamp:  match.DateStartTimestamp,
-						Result:              match.Result,
-						HomeResult:          match.HomeResult,
-						AwayResult:          match.AwayResult,
-						Partialresult:       match.Partialresult,
-						Market:              marketType,
-						Bookmaker:           lineData.Bookmaker,
-						Line:                lineData.Line,
-						LineValue:           odd.LineValue,
-						Odd:                 odd.Odd,
-						OpeningOdd:          odd.OpeningOdd,
-						OddsHistory:         formatOddsHistory(odd.OddsHistory),
-					}
-					csvRows = append(csvRows, csvRow)
-				}
-			}
+	var inputs []string
+	for _, fp := range files {
+		if slices.Contains(outputs, filepath.Clean(fp)) {
+			continue
+		}
+		if strings.HasSuffix(fp, ".ndjson") || strings.HasSuffix(fp, ".json") {
+			inputs = append(inputs, fp)
 		}
 	}
+	return inputs
+}
 
-	// Write to CSV file
-	fn := saveAs + ".csv"
-	if mode == "daily" {
-		fn = saveAs + "daily.csv"
-	}
+// processNDJSONFile streams the matches of every input into one NDJSON file
+// without holding them all in memory. A first pass counts the records of
+// every match, only matches with duplicates are kept in memory to be merged
+// and written after the others. The filter is applied to the merged matches.
+func processNDJSONFile(root string, inputs []string) error {
+	fn := outputFileName(".ndjson")
 
-	if err := writeCSV(fn, csvRows); err != nil {
-		return fmt.Errorf("error writing CSV: %w", err)
+	counts := make(map[string]int)
+	for _, fp := range inputs {
+		if fp == fn {
+			continue
+		}
+		err := readMatches(fp, func(m *Match) error {
+			counts[matchKey(m)]++
+			return nil
+		})
+		if err != nil {
+			log.Printf("error reading file %s: %v", fp, err)
+		}
 	}
 
-	fmt.Printf("Compiled %v rows of data\n", len(csvRows))
-	fmt.Println("Wrote CSV File")
-	return nil
-}
-
-func formatOddsHistory(history []OddsHistory) string {
-	if history == nil {
-		return ""
-	}
-	bytes, err := json.Marshal(history)
+	w, err := openNDJSON(fn, true)
 	if err != nil {
-		return ""
+		return err
 	}
-	return string(bytes)
-}
 
-func writeCSV(filename string, rows []CSVMatch) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
+	merger := newMatchMerger()
+	countRows := 0
+	for _, fp := range inputs {
+		if fp == fn {
+			continue
+		}
+		fmt.Printf("CHECKING AND MERGING: %v \n", fp)
+		err := readMatches(fp, func(m *Match) error {
+			tagSource(m, root, fp)
+			if counts[matchKey(m)] > 1 {
+				merger.Add(m, fp)
+				return nil
+			}
+			if !combineFilter.Keep(m) {
+				return nil
+			}
+			prepareMatch(m)
+			countRows++
+			return w.Write(m)
+		})
+		if err != nil {
+			log.Printf("error reading file %s: %v", fp, err)
+		}
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// Write header
-	header := []string{
-		"OddsportalID", "URL", "HomeTeam", "AwayTeam", "Name",
-		"EventStageName", "TournamentStageName", "TournamentName",
-		"Date", "DateStartTimestamp", "Result", "HomeResult", "AwayResult",
-		"Partialresult", "Market", "Bookmaker", "Line", "LineValue",
-		"Odd", "OpeningOdd", "OddsHistory",
-	}
-	if err := writer.Write(header); err != nil {
-		return fmt.Errorf("error writing header: %w", err)
-	}
-
-	// Write data rows
-	for _, row := range rows {
-		record := []string{
-			fmt.Sprint(row.ID),
-			row.URL,
-			row.HomeName,
-			row.AwayName,
-			row.Name,
-			row.EventStageName,
-			row.TournamentStageName,
-			row.TournamentName,
-			row.Date,
-			fmt.Sprint(row.DateStartTimestamp),
-			row.Result,
-			row.HomeResult,
-			row.AwayResult,
-			row.Partialresult,
-			row.Market,
-			row.Bookmaker,
-			row.Line,
-			row.LineValue,
-			fmt.Sprint(row.Odd),
-			fmt.Sprint(row.OpeningOdd),
-			row.OddsHistory,
+
+	for _, m := range merger.Matches() {
+		if !combineFilter.Keep(&m) {
+			continue
 		}
-		if err := writer.Write(record); err != nil {
-			return fmt.Errorf("error writing record: %w", err)
+		prepareMatch(&m)
+		countRows++
+		if err := w.Write(&m); err != nil {
+			w.atomic.Close()
+			return err
 		}
 	}
+	if err := merger.Report(); err != nil {
+		log.Printf("Error writing conflict report: %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error writing NDJSON file: %w", err)
+	}
 
+	fmt.Printf("Compiled %v rows of games \n", countRows)
+	fmt.Println("Wrote NDJSON File")
 	return nil
 }
 
+// prepareMatch fills the derived fields of a match for the combined output:
+// team aliases, bookmaker ids, dates and formatted odds.
+func prepareMatch(m *Match) {
+	resolveTeams(m)
+	resolveBookmakers(m)
+	setMatchDates(m)
+	formatMatchOdds(m)
+}
+
+// outputFileName is the combined output file with the given extension.
+func outputFileName(ext string) string {
+	if mode == "daily" {
+		return saveAs + "daily" + ext
+	}
+	return saveAs + ext
+}
+
 func processJSONFile(matches []Match) error {
 	countRows := 0
 	var content []Match
 
 	for _, match := range matches {
-		match.HomeName = retroTeamId(match.HomeName)
-		match.AwayName = retroTeamId(match.AwayName)
-		match.Date = parseMatchDate(int64(match.DateStartBase))
+		prepareMatch(&match)
 
 		countRows++
 		content = append(content, match)
 	}
 
-	if err := writeJSON(saveAs, content, countRows); err != nil {
+	if err := writeJSON(outputFileName(".json"), content, countRows); err != nil {
 		log.Fatalf("Error writing JSON: %v", err)
 	}
 
 	return nil
 }
 
-func writeJSON(saveAs string, ResultsCompiled_ []Match, countRows int) error {
-	output, err := json.Marshal(ResultsCompiled_)
-	if err != nil {
-		return fmt.Errorf("error marshalling JSON: %w", err)
-	}
-
-	if err := os.WriteFile(saveAs+".json", output, 0644); err != nil {
+func writeJSON(fn string, ResultsCompiled_ []Match, countRows int) error {
+	if err := writeMatchFile(fn, ResultsCompiled_, false); err != nil {
 		return fmt.Errorf("error writing JSON file: %w", err)
 	}
 
@@ -1,52 +1,176 @@
 package main
 
-import "github.com/chromedp/cdproto/network"
+import (
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+)
 
 const (
 	// Constants
-	BASEURL         = "https://www.oddsportal.com"
-	MAX_RETRIES     = 10
-	MAX_SLEEP       = 3
-	MIN_MICRO_SLEEP = 50
-	MAX_MICRO_SLEEP = 300
+	BASEURL           = "https://www.oddsportal.com"
+	MAX_RETRIES       = 10
+	MAX_SLEEP         = 3
+	MIN_MICRO_SLEEP   = 50
+	MAX_MICRO_SLEEP   = 300
+	SCOPE_SWITCH_WAIT = time.Second
+
+	// Rate limiting
+	DEFAULT_RATE_PER_MINUTE = 6
+	RATE_BURST              = 2
+	MAX_NAV_ATTEMPTS        = 6
+	BACKOFF_BASE            = 5 * time.Second
+	BACKOFF_MAX             = 5 * time.Minute
+	PROXY_MAX_FAILURES      = 3
+	DEFAULT_ROW_GROUP_SIZE  = 100000
+	DEFAULT_RECYCLE_AFTER   = 50
+	NDJSON_MAX_LINE         = 64 << 20
 
 	// XPATH's
 	ODDS_TABLE         = `div[data-v-49199a7b]`
 	LINE_BUTTONS       = `ul.visible-links.bg-black-main.odds-tabs.flex.w-full > li.text-white-main.odds-item`
-	FT_LINE_BUTTON     = `Array.from(document.querySelectorAll('div.tab-wrapper > div.flex-center.bg-gray-medium.h-\\[30px\\].cursor-pointer.px-3')).find(el => el.textContent.trim() === 'Full Time')?.click();`
+	SCOPE_TABS         = `div.tab-wrapper > div.flex-center.bg-gray-medium.h-\\[30px\\].cursor-pointer.px-3`
+	SCOPE_TAB_LABELS   = `Array.from(document.querySelectorAll('` + SCOPE_TABS + `')).map(el => el.textContent.trim())`
+	SCOPE_TAB_CLICK    = `Array.from(document.querySelectorAll('` + SCOPE_TABS + `')).find(el => el.textContent.trim() === %q)?.click();`
 	HIDDENLINE_BUTTONS = `ul.hidden-links.no-scrollbar.links-invisible > li`
 	MORE_BUTTON        = `div.text-white-main.ml-auto.flex.items-center.p-3.pb-\\[14px\\].pl-3.pr-1.text-xs > .drop-arrow`
-	BOOKMAKER_CELL     = `div[data-v-0e9f6ffa].border-black-borders.flex.h-9:nth-child(%d) > div:nth-child(1) > :nth-child(2) > p`
-	BOOKMAKER_CELL_TC  = `div[data-v-0e9f6ffa].border-black-borders.flex.h-9 > div:nth-child(1) > :nth-child(2) > p`
-	FIRST_CELL         = `div[data-v-0e9f6ffa].border-black-borders.flex.h-9:nth-child(%d) > div:nth-child(2) > div > div > p`
-	SECOND_CELL        = `div[data-v-0e9f6ffa].border-black-borders.flex.h-9:nth-child(%d) > div:nth-child(3) > div > div > p`
-	THIRD_CELL         = `div[data-v-0e9f6ffa].border-black-borders.flex.h-9:nth-child(%d) > div:nth-child(4) > div > div > p`
-	FIRST_CELL_TC      = `div[data-v-0e9f6ffa].border-black-borders.flex.h-9 > :nth-child(2)`
-	SECOND_CELL_TC     = `div[data-v-0e9f6ffa].border-black-borders.flex.h-9 > div:nth-child(3) > div > div > p`
-	THIRD_CELL_TC      = `div[data-v-0e9f6ffa].border-black-borders.flex.h-9 > div:nth-child(4) > div > div > p`
-	FOURTH_CELL_TC     = `div[data-v-0e9f6ffa].border-black-borders.flex.h-9 > div:nth-child(5) > div > div > p`
+	ODDS_ROWS          = `div[data-v-0e9f6ffa].border-black-borders.flex.h-9`
+
+	// ODDS_TABLE_JS returns every bookmaker row with all of its cells, and the
+	// outcome labels from the header row above the rows (bookmaker and payout
+	// columns excluded). The %s is the bookmaker filter, the cells of filtered
+	// out bookmakers are not read.
+	ODDS_TABLE_JS = `(() => {
+		const filter = %s;
+		const norm = s => s.toLowerCase().trim().replace(/\.(com|net|[a-z]{2})$/, '').replace(/[ \-_.]/g, '');
+		const wanted = name => {
+			const key = norm(name);
+			if (filter.exclude.includes(key)) return false;
+			return filter.include.length === 0 || filter.include.includes(key);
+		};
+		const rowSelector = '` + ODDS_ROWS + `';
+		const rows = Array.from(document.querySelectorAll(rowSelector));
+		let header = [];
+		if (rows.length > 0) {
+			const head = rows[0].parentElement.firstElementChild;
+			if (head && !head.matches(rowSelector)) {
+				header = Array.from(head.children).slice(1)
+					.map(el => el.textContent.trim())
+					.filter(t => t !== '' && !/payout/i.test(t));
+			}
+		}
+		return {
+			header: header,
+			rows: rows.flatMap(row => {
+				const cells = Array.from(row.children);
+				const name = (cells[0].querySelector(':scope > :nth-child(2) > p') || cells[0]).textContent.trim();
+				if (!wanted(name)) return [];
+				return [{
+					bookmaker: name,
+					cells: cells.slice(1).map(el => el.textContent.trim()),
+				}];
+			}),
+		};
+	})()`
 )
 
+// BOOKMAKERS_TO_SCRAPE are canonical ids from the bookmaker catalog
 var BOOKMAKERS_TO_SCRAPE = []string{"pinnacle", "bet365", "betfair", "unibet"}
-var TWO_COL_CELLS = []string{"#home-away;1", "#over-under;1", "#over-under;2", "#ah;1", "#ah;2", "#bts;2", "#dnb;2", "#odd-even;1"}
-var THREE_COL_CELLS = []string{"#1X2", "#double", "#eh"}
-var DONT_SCRAPE = []string{
-	"#eh;1",
-	"#eh;2",
-	"#cs;1",
-	"#cs;2",
-	"#odd-even;1",
-	"#odd-even;2",
+
+// MARKET_OUTCOMES are the outcome labels stored for the markets, the header
+// row of the odds table only labels markets not listed here. Markets grouped
+// by line (over-under, ah, eh, cs) have the line in the leading cells, an
+// empty label takes the line as outcome.
+var MARKET_OUTCOMES = map[string][]string{
+	"1X2":        {"1", "X", "2"},
+	"home-away":  {"1", "2"},
+	"over-under": {"1", "2"},
+	"ah":         {"1", "2"},
+	"eh":         {"1", "X", "2"},
+	"cs":         {""},
+	"bts":        {"Yes", "No"},
+	"double":     {"1X", "12", "X2"},
+	"dnb":        {"1", "2"},
+	"odd-even":   {"Odd", "Even"},
+	"ht-ft":      {"1/1", "1/X", "1/2", "X/1", "X/X", "X/2", "2/1", "2/X", "2/2"},
+}
+
+// MARKET_CODES are the short market names used as keys in OddsData
+var MARKET_CODES = map[string]string{
+	"1X2":        "1X2",
+	"home-away":  "HA",
+	"over-under": "OU",
+	"ah":         "AH",
+	"bts":        "BTTS",
+	"double":     "DC",
+	"eh":         "EH",
+	"dnb":        "DNB",
+	"cs":         "CS",
+	"odd-even":   "OE",
+	"ht-ft":      "HTFT",
+}
+
+// OUTPUT_FORMATS are the formats accepted by -format
+var OUTPUT_FORMATS = []string{"json", "csv", "parquet", "ndjson", "wide", "xlsx"}
+
+// SCHEMA_VERSION is the version of the stored Match, written to every JSON
+// and NDJSON file. Older files are upgraded with MIGRATIONS
+const SCHEMA_VERSION = 4
+
+// CSV_SCHEMA_VERSION is the version of the long CSV columns in CSV_COLUMNS,
+// bumped whenever a column is added, removed or changes meaning
+const CSV_SCHEMA_VERSION = 3
+
+// HISTORY_MODES are the odds history layouts accepted by -history
+var HISTORY_MODES = []string{"json", "file", "summary"}
+
+// MARKET_TABS maps the labels of the market tabs to their URL suffixes
+var MARKET_TABS = map[string]string{
+	"1X2":                 "1X2",
+	"Home/Away":           "home-away",
+	"Over/Under":          "over-under",
+	"Asian Handicap":      "ah",
+	"European Handicap":   "eh",
+	"Both Teams to Score": "bts",
+	"Double Chance":       "double",
+	"Draw No Bet":         "dnb",
+	"Correct Score":       "cs",
+	"Odd or Even":         "odd-even",
+	"Half Time/Full Time": "ht-ft",
+}
+
+// SCOPE_CODES maps the scope tab labels to the scope codes in OddRow.Scope
+var SCOPE_CODES = map[string]string{
+	"FT including OT": "ML",
+	"Full Time":       "FT",
+	"1st Half":        "H1",
+	"2nd Half":        "H2",
+	"1st Period":      "P1",
+	"2nd Period":      "P2",
+	"3rd Period":      "P3",
+	"1st Quarter":     "Q1",
+	"2nd Quarter":     "Q2",
+	"3rd Quarter":     "Q3",
+	"4th Quarter":     "Q4",
+	"1st Set":         "S1",
+	"2nd Set":         "S2",
+	"3rd Set":         "S3",
+	"4th Set":         "S4",
+	"5th Set":         "S5",
+	"1st Innings":     "I1",
+}
+
+// SCOPE_IDS is the fallback for pages without scope tabs, keyed by the scope id in the URL suffix
+var SCOPE_IDS = map[string]string{
+	"1": "ML",
+	"2": "FT",
 }
 
-var HEADERS = network.Headers{
+// BASE_HEADERS are sent with every request, the identity headers (User-Agent,
+// Accept-Language and client hints) come from the active BrowserProfile.
+var BASE_HEADERS = network.Headers{
 	"Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8",
-	"Accept-Language":           "en-US,en;q=0.5",
 	"Cache-Control":             "no-cache",
-	"User-Agent":                "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-	"Sec-Ch-Ua":                 `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`,
-	"Sec-Ch-Ua-Mobile":          "?0",
-	"Sec-Ch-Ua-Platform":        `"Windows"`,
 	"Sec-Fetch-Dest":            "document",
 	"Sec-Fetch-Mode":            "navigate",
 	"Sec-Fetch-Site":            "none",
@@ -64,3 +188,31 @@ var strictMode bool
 var outputAsCSV bool
 var toFile bool
 var isDebug bool
+var rateLimit float64
+var proxyFile string
+var profileFile string
+var deriveHints bool
+var sessionFile string
+var recycleAfter int
+var remoteURL string
+var teamsFile string
+var bookmakerFile string
+var bookmakerSpec string
+var topBookmakers int
+var marketSpec string
+var oddsInput string
+var oddsFormat string
+var timezone string
+var outputFormat string
+var compression string
+var rowGroupSize int
+var columnSpec string
+var historyMode string
+var recursive bool
+var filterFrom string
+var filterTo string
+var filterTournament string
+var filterStage string
+var filterTeam string
+var filterStatus string
+var matchTZ = time.Local
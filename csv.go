@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// CSV_COLUMNS are the columns of the long CSV (one row per outcome of a
+// bookmaker's line), followed by the history columns of the -history mode and
+// CSV_APPENDED_COLUMNS. A reader can rely on the names and order of the
+// columns of a version. Version 2 put SchemaVersion and RowID in front, since
+// then new columns are appended at the end, after the history columns.
+var CSV_COLUMNS = []string{
+	"SchemaVersion", "RowID", "OddsportalID", "URL", "HomeTeam", "AwayTeam", "HomeCanonical", "AwayCanonical",
+	"HomeOriginal", "AwayOriginal", "Name",
+	"EventStageName", "TournamentStageName", "TournamentName",
+	"Date", "LocalDate", "UTCDate", "DateStartTimestamp", "Result", "HomeResult", "AwayResult",
+	"Partialresult", "Market", "Scope", "Bookmaker", "BookmakerID", "BookmakerType", "BookmakerRegion",
+	"Line", "LineValue", "Odd", "OpeningOddDate", "OpeningOdd",
+}
+
+// CSV_APPENDED_COLUMNS are the columns added by CSV_SCHEMA_VERSION 3
+var CSV_APPENDED_COLUMNS = []string{"Season", "Source"}
+
+// HISTORY_SUMMARY_COLUMNS are the history columns of -history summary
+var HISTORY_SUMMARY_COLUMNS = []string{
+	"HistoryFirstDate", "HistoryFirst", "HistoryLastDate", "HistoryLast", "HistoryMin", "HistoryMax", "HistoryCount",
+}
+
+// HISTORY_FILE_COLUMNS are the columns of the _history.csv of -history file
+var HISTORY_FILE_COLUMNS = []string{"SchemaVersion", "RowID", "OddsportalID", "Date", "Odd", "Change"}
+
+// csvColumns is the header of the long CSV for the -history mode.
+func csvColumns() []string {
+	columns := slices.Clone(CSV_COLUMNS)
+	switch historyMode {
+	case "json":
+		columns = append(columns, "OddsHistory")
+	case "summary":
+		columns = append(columns, HISTORY_SUMMARY_COLUMNS...)
+	}
+	return append(columns, CSV_APPENDED_COLUMNS...)
+}
+
+func processCSVFile(matches []Match) error {
+	var csvRows []CSVMatch
+
+	for _, match := range matches {
+		prepareMatch(&match)
+
+		// Markets in a fixed order so the row ids are the same on every run
+		markets := make([]string, 0, len(match.OddsData))
+		for m := range match.OddsData {
+			markets = append(markets, m)
+		}
+		sort.Strings(markets)
+
+		for _, marketType := range markets {
+			for _, lineData := range match.OddsData[marketType] {
+				bm := bookmakers.Lookup(lineData.Bookmaker)
+				for _, odd := range lineData.OddsData {
+					csvRow := CSVMatch{
+						RowID:               len(csvRows) + 1,
+						ID:                  match.ID,
+						URL:                 match.URL,
+						HomeName:            match.HomeAbbreviation,
+						AwayName:            match.AwayAbbreviation,
+						HomeCanonicalName:   match.HomeCanonicalName,
+						AwayCanonicalName:   match.AwayCanonicalName,
+						HomeOriginalName:    match.HomeName,
+						AwayOriginalName:    match.AwayName,
+						Name:                match.Name,
+						EventStageName:      match.EventStageName,
+						TournamentStageName: match.TournamentStageName,
+						TournamentName:      match.TournamentName,
+						Date:                match.Date,
+						DateLocal:           match.DateLocal,
+						DateUTC:             match.DateUTC,
+						DateStartTimestamp:  match.DateStartTimestamp,
+						Result:              match.Result,
+						HomeResult:          match.HomeResult,
+						AwayResult:          match.AwayResult,
+						Partialresult:       match.Partialresult,
+						Market:              marketType,
+						Scope:               lineData.Scope,
+						Bookmaker:           lineData.Bookmaker,
+						BookmakerID:         lineData.BookmakerID,
+						BookmakerType:       bm.Type(),
+						BookmakerRegion:     bm.region(),
+						Line:                lineData.Line,
+						LineValue:           odd.LineValue,
+						Odd:                 formatOdds(odd.Odd, oddsFormat),
+						OpeningOddDate:      odd.OpeningOdd.Date,
+						OpeningOdd:          formatOdds(odd.OpeningOdd.Odds, oddsFormat),
+						OddsHistory:         odd.OddsHistory,
+						Season:              match.Season,
+						Source:              match.Source,
+					}
+					csvRows = append(csvRows, csvRow)
+				}
+			}
+		}
+	}
+
+	// Write to CSV file
+	if err := writeCSV(outputFileName(".csv"), csvRows); err != nil {
+		return fmt.Errorf("error writing CSV: %w", err)
+	}
+	if historyMode == "file" {
+		if err := writeHistoryCSV(outputFileName("_history.csv"), csvRows); err != nil {
+			return fmt.Errorf("error writing history CSV: %w", err)
+		}
+	}
+
+	fmt.Printf("Compiled %v rows of data\n", len(csvRows))
+	fmt.Println("Wrote CSV File")
+	return nil
+}
+
+func formatOddsHistory(history []OddsHistory) string {
+	if history == nil {
+		return ""
+	}
+	bytes, err := json.Marshal(history)
+	if err != nil {
+		return ""
+	}
+	return string(bytes)
+}
+
+// summarizeOddsHistory gives the HISTORY_SUMMARY_COLUMNS of a history, the
+// first and last odds by date. Empty without history.
+func summarizeOddsHistory(history []OddsHistory) []string {
+	var valid []OddsHistory
+	for _, h := range history {
+		if h.Odds > 1 {
+			valid = append(valid, h)
+		}
+	}
+	if len(valid) == 0 {
+		return []string{"", "", "", "", "", "", "0"}
+	}
+
+	// The dates sort as strings (2024-01-01 or RFC 3339)
+	sort.SliceStable(valid, func(i, j int) bool { return valid[i].Date < valid[j].Date })
+	lo, hi := valid[0].Odds, valid[0].Odds
+	for _, h := range valid {
+		lo = math.Min(lo, h.Odds)
+		hi = math.Max(hi, h.Odds)
+	}
+	first, last := valid[0], valid[len(valid)-1]
+	return []string{
+		first.Date, formatOdds(first.Odds, oddsFormat),
+		last.Date, formatOdds(last.Odds, oddsFormat),
+		formatOdds(lo, oddsFormat), formatOdds(hi, oddsFormat),
+		strconv.Itoa(len(valid)),
+	}
+}
+
+func writeCSV(filename string, rows []CSVMatch) error {
+	file, err := createAtomic(filename, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	// Write header
+	if err := writer.Write(csvColumns()); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+
+	// Write data rows
+	version := strconv.Itoa(CSV_SCHEMA_VERSION)
+	for _, row := range rows {
+		record := []string{
+			version,
+			fmt.Sprint(row.RowID),
+			fmt.Sprint(row.ID),
+			row.URL,
+			row.HomeName,
+			row.AwayName,
+			row.HomeCanonicalName,
+			row.AwayCanonicalName,
+			row.HomeOriginalName,
+			row.AwayOriginalName,
+			row.Name,
+			row.EventStageName,
+			row.TournamentStageName,
+			row.TournamentName,
+			row.Date,
+			row.DateLocal,
+			row.DateUTC,
+			fmt.Sprint(row.DateStartTimestamp),
+			row.Result,
+			row.HomeResult,
+			row.AwayResult,
+			row.Partialresult,
+			row.Market,
+			row.Scope,
+			row.Bookmaker,
+			row.BookmakerID,
+			row.BookmakerType,
+			row.BookmakerRegion,
+			row.Line,
+			row.LineValue,
+			row.Odd,
+			row.OpeningOddDate,
+			row.OpeningOdd,
+		}
+		switch historyMode {
+		case "json":
+			record = append(record, formatOddsHistory(row.OddsHistory))
+		case "summary":
+			record = append(record, summarizeOddsHistory(row.OddsHistory)...)
+		}
+		record = append(record, row.Season, row.Source)
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("error writing record: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Commit()
+}
+
+// writeHistoryCSV writes one row per odds change, joined to the long CSV by
+// RowID.
+func writeHistoryCSV(filename string, rows []CSVMatch) error {
+	file, err := createAtomic(filename, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	if err := writer.Write(HISTORY_FILE_COLUMNS); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+
+	version := strconv.Itoa(CSV_SCHEMA_VERSION)
+	for _, row := range rows {
+		for _, h := range row.OddsHistory {
+			record := []string{
+				version,
+				fmt.Sprint(row.RowID),
+				fmt.Sprint(row.ID),
+				h.Date,
+				formatOdds(h.Odds, oddsFormat),
+				h.Change,
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("error writing record: %w", err)
+			}
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Commit()
+}
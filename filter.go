@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CombineFilter selects the matches written by combine. Every set criterion
+// has to match, a list matches if any of its entries does.
+type CombineFilter struct {
+	from        time.Time // Start of the -from day in -tz
+	to          time.Time // Start of the day after -to in -tz
+	tournaments []string  // Substrings of the tournament name, lower case
+	stages      []string  // Substrings of the tournament or event stage name, lower case
+	teams       []string  // Original or canonical names or abbreviations
+	statuses    []string  // RESULT_STATUSES
+}
+
+// RESULT_STATUSES are the statuses accepted by -status
+var RESULT_STATUSES = []string{"finished", "scheduled", "canceled", "postponed", "abandoned", "awarded", "interrupted"}
+
+var combineFilter *CombineFilter
+
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+func lowerList(s string) []string {
+	list := splitList(s)
+	for i := range list {
+		list[i] = strings.ToLower(list[i])
+	}
+	return list
+}
+
+// parseCombineFilter parses the -from/-to dates (2006-01-02 in -tz,
+// inclusive) and the comma separated -tournament, -stage, -team and -status
+// lists.
+func parseCombineFilter(from string, to string, tournament string, stage string, team string, status string) (*CombineFilter, error) {
+	f := &CombineFilter{
+		tournaments: lowerList(tournament),
+		stages:      lowerList(stage),
+		teams:       splitList(team),
+		statuses:    lowerList(status),
+	}
+
+	if from != "" {
+		d, err := time.ParseInLocation(time.DateOnly, from, matchTZ)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -from date %q: %v", from, err)
+		}
+		f.from = d
+	}
+	if to != "" {
+		d, err := time.ParseInLocation(time.DateOnly, to, matchTZ)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -to date %q: %v", to, err)
+		}
+		f.to = d.AddDate(0, 0, 1)
+	}
+	if !f.from.IsZero() && !f.to.IsZero() && !f.from.Before(f.to) {
+		return nil, fmt.Errorf("-from %s is after -to %s", from, to)
+	}
+	for _, s := range f.statuses {
+		if !slices.Contains(RESULT_STATUSES, s) {
+			return nil, fmt.Errorf("invalid status %s, must be one of %v", s, RESULT_STATUSES)
+		}
+	}
+	return f, nil
+}
+
+// resultStatus is the status of a match: the cancellation or postponement
+// from the event stage, else finished with a result and scheduled without.
+func resultStatus(m *Match) string {
+	stage := strings.ToLower(m.EventStageName)
+	for _, s := range []string{"canceled", "postponed", "abandoned", "awarded", "interrupted"} {
+		if strings.Contains(stage, s) || (s == "canceled" && strings.Contains(stage, "cancelled")) {
+			return s
+		}
+	}
+	if m.Result != "" {
+		return "finished"
+	}
+	return "scheduled"
+}
+
+func containsAny(s string, substrings []string) bool {
+	s = strings.ToLower(s)
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// wantsTeam reports whether either team is one of the filter's teams.
+func (f *CombineFilter) wantsTeam(m *Match) bool {
+	resolveTeams(m)
+	names := []string{
+		m.HomeName, m.HomeCanonicalName, m.HomeAbbreviation,
+		m.AwayName, m.AwayCanonicalName, m.AwayAbbreviation,
+	}
+	for _, t := range f.teams {
+		for _, n := range names {
+			if strings.EqualFold(t, n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Keep reports whether the match passes the filter. The odds of kept matches
+// are cut down to the -markets and -bookmakers selection.
+func (f *CombineFilter) Keep(m *Match) bool {
+	if f != nil {
+		start := matchStart(m)
+		if !f.from.IsZero() && start.Before(f.from) {
+			return false
+		}
+		if !f.to.IsZero() && !start.Before(f.to) {
+			return false
+		}
+		if len(f.tournaments) > 0 && !containsAny(m.TournamentName, f.tournaments) {
+			return false
+		}
+		if len(f.stages) > 0 && !containsAny(m.TournamentStageName, f.stages) && !containsAny(m.EventStageName, f.stages) {
+			return false
+		}
+		if len(f.teams) > 0 && !f.wantsTeam(m) {
+			return false
+		}
+		if len(f.statuses) > 0 && !slices.Contains(f.statuses, resultStatus(m)) {
+			return false
+		}
+	}
+
+	if m.OddsData == nil {
+		return true
+	}
+	// The merger still holds the odds map and its rows, the cut down odds go
+	// into a new map
+	odds := make(map[string][]OddRow, len(m.OddsData))
+	for market, rows := range m.OddsData {
+		if !marketFilter.WantsKey(market) {
+			continue
+		}
+		var kept []OddRow
+		for _, r := range rows {
+			if bookmakerFilter.Wants(r.Bookmaker) {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) > 0 {
+			odds[market] = kept
+		}
+	}
+	m.OddsData = odds
+	return true
+}
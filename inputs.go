@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// findInputs lists the files -f selects for combine, along with the root
+// folder the seasons are named after. -f is a file, a folder whose files are
+// read (with -recursive the files of every subfolder too) or a glob pattern
+// where '**' matches any number of folders, e.g. './data/NHL/**/*.json'.
+func findInputs(pattern string, recursive bool) (string, []string, error) {
+	pattern = filepath.Clean(filepath.FromSlash(pattern))
+	if !strings.ContainsAny(pattern, "*?[") {
+		info, err := os.Stat(pattern)
+		if err != nil {
+			return "", nil, err
+		}
+		if !info.IsDir() {
+			return filepath.Dir(pattern), []string{pattern}, nil
+		}
+		if recursive {
+			pattern = filepath.Join(pattern, "**", "*")
+		} else {
+			pattern = filepath.Join(pattern, "*")
+		}
+	}
+
+	root, rest := splitGlob(pattern)
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if matchGlob(rest, strings.Split(rel, string(filepath.Separator))) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return root, files, err
+}
+
+// splitGlob splits a pattern into the folder in front of the first wildcard
+// and the pattern segments after it.
+func splitGlob(pattern string) (string, []string) {
+	segments := strings.Split(pattern, string(filepath.Separator))
+	i := 0
+	for i < len(segments) && !strings.ContainsAny(segments[i], "*?[") {
+		i++
+	}
+
+	root := strings.Join(segments[:i], string(filepath.Separator))
+	if root == "" {
+		root = "."
+		if strings.HasPrefix(pattern, string(filepath.Separator)) {
+			root = string(filepath.Separator)
+		}
+	}
+	return root, segments[i:]
+}
+
+// matchGlob matches path segments against pattern segments, '**' matches
+// zero or more segments.
+func matchGlob(pattern []string, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchGlob(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	ok, _ := filepath.Match(pattern[0], path[0])
+	return ok && matchGlob(pattern[1:], path[1:])
+}
+
+// tagSource sets the file a match was read from, relative to the input root,
+// and its season: the folder of the file, or the root's name for files at
+// the top, e.g. '20182019' for ./data/NHL/20182019/NHL_01.json.
+func tagSource(m *Match, root string, file string) {
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		rel = file
+	}
+	m.Source = filepath.ToSlash(rel)
+
+	season := filepath.Dir(rel)
+	if season == "." {
+		if abs, err := filepath.Abs(root); err == nil {
+			root = abs
+		}
+		season = filepath.Base(root)
+	}
+	m.Season = filepath.ToSlash(season)
+}
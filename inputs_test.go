@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.json", "NHL_01.json", true},
+		{"*.json", "20182019/NHL_01.json", false},
+		{"**/*.json", "NHL_01.json", true}, // ** matches zero folders
+		{"**/*.json", "20182019/NHL_01.json", true},
+		{"**/*.json", "a/b/c/NHL_01.json", true},
+		{"**/*.json", "20182019/NHL_01.ndjson", false},
+		{"2018*/**", "20182019/NHL_01.json", true},
+		{"2018*/**", "20182019", true},
+		{"**", "a/b", true},
+		{"**/2018*/*.json", "NHL/20182019/NHL_01.json", true},
+		{"**/2018*/*.json", "NHL/20192020/NHL_01.json", false},
+		{"a/**/b/*.json", "a/b/x.json", true},
+		{"a/**/b/*.json", "a/x/y/b/x.json", true},
+		{"a/**/b/*.json", "a/x/y/c/x.json", false},
+		{"NHL_0[1-3].json", "NHL_02.json", true},
+		{"NHL_0[1-3].json", "NHL_04.json", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(strings.Split(tt.pattern, "/"), strings.Split(tt.path, "/")); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSplitGlob(t *testing.T) {
+	sep := string(filepath.Separator)
+	tests := []struct {
+		pattern string
+		root    string
+		rest    string
+	}{
+		{"data/NHL/**/*.json", "data/NHL", "**/*.json"},
+		{"data/NHL/2018*/NHL_*.json", "data/NHL", "2018*/NHL_*.json"},
+		{"*.json", ".", "*.json"},
+		{"/data/*/x.json", "/data", "*/x.json"},
+		{"/*.json", "/", "*.json"},
+	}
+	for _, tt := range tests {
+		root, rest := splitGlob(filepath.FromSlash(tt.pattern))
+		if root != filepath.FromSlash(tt.root) || strings.Join(rest, sep) != filepath.FromSlash(tt.rest) {
+			t.Errorf("splitGlob(%q) = %q, %q, want %q, %q", tt.pattern, root, rest, tt.root, tt.rest)
+		}
+	}
+}
+
+func TestTagSource(t *testing.T) {
+	root := filepath.Join("data", "NHL")
+	tests := []struct {
+		file   string
+		season string
+		source string
+	}{
+		{filepath.Join(root, "20182019", "NHL_01.json"), "20182019", "20182019/NHL_01.json"},
+		{filepath.Join(root, "2018", "regular", "NHL_01.json"), "2018/regular", "2018/regular/NHL_01.json"},
+		{filepath.Join(root, "NHL_01.json"), "NHL", "NHL_01.json"},
+	}
+	for _, tt := range tests {
+		var m Match
+		tagSource(&m, root, tt.file)
+		if m.Season != tt.season || m.Source != tt.source {
+			t.Errorf("tagSource(%q) = season %q source %q, want %q %q", tt.file, m.Season, m.Source, tt.season, tt.source)
+		}
+	}
+}
+
+func TestFindInputs(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{"NHL_01.json", "20182019/NHL_01.json", "20182019/NHL_01.ndjson", "20192020/po/NHL_01.json"}
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		pattern   string
+		recursive bool
+		want      []string
+	}{
+		{dir, false, []string{"NHL_01.json"}},
+		{dir, true, files},
+		{filepath.Join(dir, "**", "*.json"), false, []string{"NHL_01.json", "20182019/NHL_01.json", "20192020/po/NHL_01.json"}},
+		{filepath.Join(dir, "2018*", "*"), false, []string{"20182019/NHL_01.json", "20182019/NHL_01.ndjson"}},
+		{filepath.Join(dir, "NHL_01.json"), false, []string{"NHL_01.json"}},
+	}
+	for _, tt := range tests {
+		root, got, err := findInputs(tt.pattern, tt.recursive)
+		if err != nil {
+			t.Fatalf("findInputs(%q): %v", tt.pattern, err)
+		}
+		if root != dir {
+			t.Errorf("findInputs(%q) root %q, want %q", tt.pattern, root, dir)
+		}
+		for i := range got {
+			rel, _ := filepath.Rel(dir, got[i])
+			got[i] = filepath.ToSlash(rel)
+		}
+		slices.Sort(got)
+		want := slices.Clone(tt.want)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("findInputs(%q, %v) = %v, want %v", tt.pattern, tt.recursive, got, want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// journalPath is the write-ahead journal of a page file, e.g.
+// NHL_01.json.journal for NHL_01.json.
+func journalPath(file string) string {
+	return file + ".journal"
+}
+
+// openJournal opens the journal of a page file for appending. Every scraped
+// match is appended and synced to the journal before the page file is
+// rewritten, so a crash during the rewrite loses nothing.
+func openJournal(file string) (*NDJSONWriter, error) {
+	return openNDJSON(journalPath(file), false)
+}
+
+// savePage journals the scraped match, then rewrites the page file with it.
+func savePage(file string, journal *NDJSONWriter, matches []Match, m *Match) error {
+	if err := journal.Write(m); err != nil {
+		return fmt.Errorf("error writing match %s to journal: %v", m.URL, err)
+	}
+	return writeMatchFile(file, matches, true)
+}
+
+// closeJournal closes the journal of a page file and removes it when the page
+// file has every journaled match, i.e. its last save succeeded. Otherwise it
+// is kept and replayed by the next run.
+func closeJournal(w *NDJSONWriter, file string, saved bool) {
+	if err := w.Close(); err != nil {
+		printLog(fmt.Sprintf("Error closing journal of %s: %v", file, err))
+		return
+	}
+	if !saved {
+		printLog(fmt.Sprintf("Keeping the journal of %s, the page file is not up to date", file))
+		return
+	}
+	if err := os.Remove(journalPath(file)); err != nil && !os.IsNotExist(err) {
+		printLog(fmt.Sprintf("Error removing journal of %s: %v", file, err))
+	}
+}
+
+// loadPage reads a page file for scraping odds. A corrupt page file is kept
+// as <file>.corrupt and salvaged up to its last complete match, then the
+// matches in the journal of an interrupted run are replayed on top. The
+// recovered page file is written back and the journal removed.
+func loadPage(file string) ([]Match, error) {
+	corrupt := false
+	matches, _, err := readMatchFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		data, rerr := os.ReadFile(file)
+		if rerr != nil {
+			return nil, rerr
+		}
+		matches = salvageMatches(data)
+		printLog(fmt.Sprintf("Page file %s is corrupt (%v), salvaged %d matches", file, err, len(matches)))
+		corrupt = true
+	}
+
+	replayed := 0
+	err = readMatches(journalPath(file), func(m *Match) error {
+		replayed++
+		for i := range matches {
+			if matches[i].ID == m.ID {
+				matches[i] = *m
+				return nil
+			}
+		}
+		matches = append(matches, *m)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error replaying journal: %v", err)
+	}
+	if replayed > 0 {
+		printLog(fmt.Sprintf("Replayed %d matches from the journal of %s", replayed, file))
+	}
+
+	if corrupt {
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("page file %s is corrupt and has no journal to recover from, scrape it again with -m base", file)
+		}
+		if err := os.Rename(file, corruptPath(file)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if corrupt || replayed > 0 {
+		if err := writeMatchFile(file, matches, true); err != nil {
+			return nil, fmt.Errorf("error writing recovered page file: %v", err)
+		}
+	}
+	if err := os.Remove(journalPath(file)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// corruptPath is the first free backup name for a corrupt page file,
+// <file>.corrupt or, if that is taken by an earlier one, <file>.corrupt.2 etc.
+func corruptPath(file string) string {
+	path := file + ".corrupt"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s.corrupt.%d", file, i)
+	}
+}
+
+// salvageMatches decodes the complete matches at the start of a damaged
+// match file of any version, e.g. one cut short by a crash or a full disk.
+func salvageMatches(data []byte) []Match {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil
+	}
+
+	version := 1
+	if tok == json.Delim('{') {
+		version = 0
+		found := false
+		for !found && dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil
+			}
+			switch key {
+			case "schema_version":
+				if err := dec.Decode(&version); err != nil {
+					return nil
+				}
+			case "matches":
+				if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+					return nil
+				}
+				found = true
+			default:
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					return nil
+				}
+			}
+		}
+		if !found || version == 0 {
+			return nil
+		}
+	} else if tok != json.Delim('[') {
+		return nil
+	}
+
+	var matches []Match
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			break
+		}
+		m, err := upgradeMatch(raw, version)
+		if err != nil {
+			break
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func journalMatch(t *testing.T, file string, m *Match) {
+	t.Helper()
+	w, err := openJournal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPageReplaysJournalWithoutBackup(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "NHL_01.json")
+	if err := writeMatchFile(file, []Match{{ID: 1}, {ID: 2}}, true); err != nil {
+		t.Fatal(err)
+	}
+	journalMatch(t, file, &Match{ID: 2, Result: "2:1"})
+
+	matches, err := loadPage(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches[1].Result != "2:1" {
+		t.Errorf("got %+v, want match 2 replayed from the journal", matches)
+	}
+	if _, err := os.Stat(file + ".corrupt"); !os.IsNotExist(err) {
+		t.Error("a healthy page file was kept as corrupt")
+	}
+	if _, err := os.Stat(journalPath(file)); !os.IsNotExist(err) {
+		t.Error("the journal was not removed")
+	}
+}
+
+func TestLoadPageKeepsEveryCorruptBackup(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "NHL_01.json")
+	for i, want := range []string{file + ".corrupt", file + ".corrupt.2"} {
+		broken := []byte(`{"schema_version":2,"matches":[{"id":1},{"id":2,"url":"/a`)
+		if err := os.WriteFile(file, broken, 0644); err != nil {
+			t.Fatal(err)
+		}
+		matches, err := loadPage(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 1 || matches[0].ID != 1 {
+			t.Errorf("run %d salvaged %+v, want match 1", i+1, matches)
+		}
+		data, err := os.ReadFile(want)
+		if err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+		if string(data) != string(broken) {
+			t.Errorf("run %d: %s does not hold the corrupt file", i+1, want)
+		}
+	}
+}
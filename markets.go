@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MarketFilter selects the markets to scrape by their OddsData key, e.g.
+// '1X2', 'OU-FT' or 'AH-P1'. 'OU-*' selects every scope of a market.
+type MarketFilter struct {
+	keys     map[string]bool
+	prefixes []string
+}
+
+// MarketReport records the markets left out of a match on purpose and the
+// ones that failed, so a missing market can be told apart from a broken one.
+type MarketReport struct {
+	Skipped []string
+	Failed  []string
+}
+
+var marketFilter *MarketFilter
+
+// parseMarketFilter parses a comma separated list of market keys.
+func parseMarketFilter(spec string) (*MarketFilter, error) {
+	f := &MarketFilter{keys: make(map[string]bool)}
+	for _, e := range strings.Split(spec, ",") {
+		e = strings.ToUpper(strings.TrimSpace(e))
+		if e == "" {
+			continue
+		}
+		if code, ok := strings.CutSuffix(e, "-*"); ok {
+			f.prefixes = append(f.prefixes, code)
+			if code == "HA" {
+				f.keys["ML"] = true
+			}
+			continue
+		}
+		f.keys[e] = true
+	}
+	if len(f.keys) == 0 && len(f.prefixes) == 0 {
+		return nil, fmt.Errorf("no markets in %q", spec)
+	}
+	return f, nil
+}
+
+// WantsKey reports whether the market with the OddsData key should be scraped.
+func (f *MarketFilter) WantsKey(key string) bool {
+	if f == nil || f.keys[key] {
+		return true
+	}
+	for _, code := range f.prefixes {
+		if key == code || strings.HasPrefix(key, code+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// WantsMarket reports whether any scope of the market suffix is wanted.
+func (f *MarketFilter) WantsMarket(suffix string) bool {
+	if f == nil {
+		return true
+	}
+	scopes := []string{""}
+	for _, code := range SCOPE_CODES {
+		scopes = append(scopes, code)
+	}
+	for _, code := range SCOPE_IDS {
+		scopes = append(scopes, code)
+	}
+	for _, scope := range scopes {
+		if f.WantsKey(parseLineValue(suffix, scope)) {
+			return true
+		}
+	}
+	return false
+}
+
+// marketSuffix returns the URL suffix of a market tab from its label, or an
+// empty string for tabs we don't know.
+func marketSuffix(label string) string {
+	key := strings.ToLower(strings.Join(strings.Fields(label), ""))
+	for name, suffix := range MARKET_TABS {
+		if strings.ToLower(strings.ReplaceAll(name, " ", "")) == key {
+			return suffix
+		}
+	}
+	return ""
+}
+
+func (r *MarketReport) skip(market string) {
+	printDebug(fmt.Sprintf("Skipping market %s", market))
+	r.Skipped = append(r.Skipped, market)
+}
+
+func (r *MarketReport) fail(market string) {
+	r.Failed = append(r.Failed, market)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Conflict is a field two records of the same match disagree on, e.g. a
+// result that was corrected after the first scrape.
+type Conflict struct {
+	Key           string
+	Field         string
+	Kept          string
+	Dropped       string
+	KeptSource    string
+	DroppedSource string
+}
+
+// CONFLICT_COLUMNS are the columns of the _conflicts.csv report
+var CONFLICT_COLUMNS = []string{"Match", "Field", "Kept", "Dropped", "KeptSource", "DroppedSource"}
+
+// MatchMerger combines the records of the inputs into one record per match,
+// keyed on matchKey. Matches keep the order they were first seen in.
+type MatchMerger struct {
+	keys       []string
+	matches    map[string]*Match
+	sources    map[string]string
+	duplicates int
+	Conflicts  []Conflict
+}
+
+func newMatchMerger() *MatchMerger {
+	return &MatchMerger{matches: make(map[string]*Match), sources: make(map[string]string)}
+}
+
+// matchKey identifies a match by its OddsPortal id, encoded event id or URL.
+// Records with none of them have an empty key and are never merged.
+func matchKey(m *Match) string {
+	switch {
+	case m.ID != 0:
+		return strconv.Itoa(m.ID)
+	case m.EncodeEventID != "":
+		return m.EncodeEventID
+	}
+	return m.URL
+}
+
+// Add merges the record read from source into the match it belongs to.
+func (mm *MatchMerger) Add(m *Match, source string) {
+	key := matchKey(m)
+	if key == "" {
+		key = fmt.Sprintf("#%d", len(mm.keys)+1)
+	}
+	prev, ok := mm.matches[key]
+	if !ok {
+		c := *m
+		mm.keys = append(mm.keys, key)
+		mm.matches[key] = &c
+		mm.sources[key] = source
+		return
+	}
+
+	mm.duplicates++
+	merged := mm.merge(key, prev, m, mm.sources[key], source)
+	mm.matches[key] = &merged
+	if !strings.Contains("+"+mm.sources[key]+"+", "+"+source+"+") {
+		mm.sources[key] += "+" + source
+	}
+}
+
+// Matches returns the merged matches.
+func (mm *MatchMerger) Matches() []Match {
+	matches := make([]Match, 0, len(mm.keys))
+	for _, k := range mm.keys {
+		matches = append(matches, *mm.matches[k])
+	}
+	return matches
+}
+
+// merge combines two records of a match. The newer snapshot (by scraped_at,
+// the later input without it) gives the match fields unless only the older
+// one has the result. The odds are merged by market and bookmaker line, the
+// newer snapshot wins for lines both have.
+func (mm *MatchMerger) merge(key string, a *Match, b *Match, aSource string, bSource string) Match {
+	newer, older := b, a
+	newerSource, olderSource := bSource, aSource
+	if a.ScrapedAt > b.ScrapedAt {
+		newer, older = a, b
+		newerSource, olderSource = aSource, bSource
+	}
+
+	base, other := newer, older
+	baseSource, otherSource := newerSource, olderSource
+	if newer.Result == "" && older.Result != "" {
+		base, other = older, newer
+		baseSource, otherSource = olderSource, newerSource
+	}
+
+	fields := [][3]string{
+		{"result", base.Result, other.Result},
+		{"home_result", base.HomeResult, other.HomeResult},
+		{"away_result", base.AwayResult, other.AwayResult},
+		{"partialresult", base.Partialresult, other.Partialresult},
+		{"date_start_timestamp", strconv.Itoa(base.DateStartTimestamp), strconv.Itoa(other.DateStartTimestamp)},
+		{"home_name", base.HomeName, other.HomeName},
+		{"away_name", base.AwayName, other.AwayName},
+	}
+	for _, f := range fields {
+		if f[1] != "" && f[2] != "" && f[1] != "0" && f[2] != "0" && f[1] != f[2] {
+			mm.Conflicts = append(mm.Conflicts, Conflict{
+				Key:           key,
+				Field:         f[0],
+				Kept:          f[1],
+				Dropped:       f[2],
+				KeptSource:    baseSource,
+				DroppedSource: otherSource,
+			})
+		}
+	}
+
+	merged := *base
+	merged.OddsData = mergeOdds(newer.OddsData, older.OddsData)
+	merged.ScrapedAt = newer.ScrapedAt
+	merged.MarketsSkipped = missingMarkets(merged.OddsData, newer.MarketsSkipped, older.MarketsSkipped)
+	merged.MarketsFailed = missingMarkets(merged.OddsData, newer.MarketsFailed, older.MarketsFailed)
+	return merged
+}
+
+func oddRowKey(r *OddRow) string {
+	bookmaker := r.BookmakerID
+	if bookmaker == "" {
+		bookmaker = normalizeBookmaker(r.Bookmaker)
+	}
+	return bookmaker + "|" + r.Scope + "|" + r.Line
+}
+
+// mergeOdds returns the markets of both snapshots, lines of a bookmaker in
+// both come from newer.
+func mergeOdds(newer map[string][]OddRow, older map[string][]OddRow) map[string][]OddRow {
+	if len(older) == 0 {
+		return newer
+	}
+	if len(newer) == 0 {
+		return older
+	}
+
+	merged := make(map[string][]OddRow, len(newer))
+	for market, rows := range newer {
+		merged[market] = slices.Clone(rows)
+	}
+	for market, rows := range older {
+		have := make(map[string]bool)
+		for i := range merged[market] {
+			have[oddRowKey(&merged[market][i])] = true
+		}
+		for i := range rows {
+			if !have[oddRowKey(&rows[i])] {
+				merged[market] = append(merged[market], rows[i])
+			}
+		}
+	}
+	return merged
+}
+
+// missingMarkets is the union of the market lists without the markets that
+// have odds after the merge.
+func missingMarkets(odds map[string][]OddRow, lists ...[]string) []string {
+	var markets []string
+	for _, l := range lists {
+		for _, m := range l {
+			if _, ok := odds[m]; !ok && !slices.Contains(markets, m) {
+				markets = append(markets, m)
+			}
+		}
+	}
+	return markets
+}
+
+// Report logs the merge and writes the conflicts to <name>_conflicts.csv.
+func (mm *MatchMerger) Report() error {
+	printLog(fmt.Sprintf("Merged %d duplicate records into %d matches, %d conflicts", mm.duplicates, len(mm.keys), len(mm.Conflicts)))
+	if len(mm.Conflicts) == 0 {
+		return nil
+	}
+
+	file, err := createAtomic(outputFileName("_conflicts.csv"), 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(CONFLICT_COLUMNS); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+	for _, c := range mm.Conflicts {
+		printLog(fmt.Sprintf("Conflict in match %s: %s, kept %q (%s), dropped %q (%s)", c.Key, c.Field, c.Kept, c.KeptSource, c.Dropped, c.DroppedSource))
+		if err := writer.Write([]string{c.Key, c.Field, c.Kept, c.Dropped, c.KeptSource, c.DroppedSource}); err != nil {
+			return fmt.Errorf("error writing record: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Commit()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeOdds(t *testing.T) {
+	row := func(bookmaker string, line string, odd float64) OddRow {
+		return OddRow{BookmakerID: bookmaker, Scope: "FT", Line: line, OddsData: []OddsData{{LineValue: "1", Odd: odd}}}
+	}
+	newer := map[string][]OddRow{
+		"1X2":   {row("pinnacle", "1X2", 2.1)},
+		"OU-FT": {row("pinnacle", "5.5", 1.9)},
+	}
+	older := map[string][]OddRow{
+		"1X2":   {row("pinnacle", "1X2", 2.3), row("bet365", "1X2", 2.2)},
+		"OU-FT": {row("pinnacle", "6.5", 2.6)},
+		"BTTS":  {row("unibet", "BTTS", 1.7)},
+	}
+
+	merged := mergeOdds(newer, older)
+	tests := []struct {
+		market string
+		want   []float64 // odds of the rows in order
+	}{
+		{"1X2", []float64{2.1, 2.2}},   // newer wins for pinnacle, bet365 only in older
+		{"OU-FT", []float64{1.9, 2.6}}, // different lines are both kept
+		{"BTTS", []float64{1.7}},       // a market only in older
+	}
+	for _, tt := range tests {
+		var got []float64
+		for _, r := range merged[tt.market] {
+			got = append(got, r.OddsData[0].Odd)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s odds %v, want %v", tt.market, got, tt.want)
+		}
+	}
+	if len(merged) != 3 {
+		t.Errorf("got %d markets, want 3", len(merged))
+	}
+	if newer["1X2"][0].OddsData[0].Odd != 2.1 || len(newer["1X2"]) != 1 {
+		t.Error("mergeOdds changed its newer argument")
+	}
+
+	if got := mergeOdds(nil, older); len(got) != len(older) {
+		t.Errorf("mergeOdds(nil, older) has %d markets, want %d", len(got), len(older))
+	}
+	if got := mergeOdds(newer, nil); len(got) != len(newer) {
+		t.Errorf("mergeOdds(newer, nil) has %d markets, want %d", len(got), len(newer))
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name       string
+		a, b       Match
+		wantResult string
+		wantHome   string
+		wantAt     string
+		conflicts  []string
+	}{
+		{
+			name:      "newer by scraped_at wins",
+			a:         Match{ID: 1, HomeName: "Bruins", ScrapedAt: "2024-01-02T00:00:00Z"},
+			b:         Match{ID: 1, HomeName: "Boston Bruins", ScrapedAt: "2024-01-01T00:00:00Z"},
+			wantHome:  "Bruins",
+			wantAt:    "2024-01-02T00:00:00Z",
+			conflicts: []string{"home_name"},
+		},
+		{
+			name:       "later input wins without scraped_at",
+			a:          Match{ID: 1, Result: "2:1"},
+			b:          Match{ID: 1, Result: "3:1"},
+			wantResult: "3:1",
+			conflicts:  []string{"result"},
+		},
+		{
+			name:       "a result beats a newer record without one",
+			a:          Match{ID: 1, Result: "2:1", ScrapedAt: "2024-01-01T00:00:00Z"},
+			b:          Match{ID: 1, ScrapedAt: "2024-01-02T00:00:00Z"},
+			wantResult: "2:1",
+			wantAt:     "2024-01-02T00:00:00Z",
+		},
+		{
+			name:       "empty and zero fields are no conflict",
+			a:          Match{ID: 1, Result: "2:1", DateStartTimestamp: 1700000000},
+			b:          Match{ID: 1, Result: "2:1"},
+			wantResult: "2:1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm := newMatchMerger()
+			mm.Add(&tt.a, "a.json")
+			mm.Add(&tt.b, "b.json")
+
+			matches := mm.Matches()
+			if len(matches) != 1 {
+				t.Fatalf("got %d matches, want 1", len(matches))
+			}
+			m := matches[0]
+			if m.Result != tt.wantResult || m.HomeName != tt.wantHome || m.ScrapedAt != tt.wantAt {
+				t.Errorf("merged result %q home %q scraped_at %q, want %q %q %q", m.Result, m.HomeName, m.ScrapedAt, tt.wantResult, tt.wantHome, tt.wantAt)
+			}
+			var fields []string
+			for _, c := range mm.Conflicts {
+				fields = append(fields, c.Field)
+			}
+			if !slices.Equal(fields, tt.conflicts) {
+				t.Errorf("conflicts %v, want %v", fields, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestMatchMergerKeys(t *testing.T) {
+	mm := newMatchMerger()
+	for _, m := range []Match{
+		{ID: 1},
+		{ID: 1, URL: "/other"},
+		{EncodeEventID: "abc"},
+		{EncodeEventID: "abc"},
+		{URL: "/hockey/usa/nhl/a-b"},
+		{URL: "/hockey/usa/nhl/a-b"},
+		{},
+		{},
+	} {
+		mm.Add(&m, "a.json")
+	}
+	if got := len(mm.Matches()); got != 5 {
+		t.Errorf("got %d matches, want 5 (records without any key are never merged)", got)
+	}
+	if mm.duplicates != 3 {
+		t.Errorf("got %d duplicates, want 3", mm.duplicates)
+	}
+}
@@ -1,130 +1,236 @@
 package main
 
-import "github.com/chromedp/cdproto/cdp"
-
+// Match is a match as stored in the page files and the combined output, at
+// SCHEMA_VERSION. It is built from the site's RawMatch by newMatch, files of
+// older versions are upgraded by migrateMatch.
 type Match struct {
-	ID                      int      `json:"id"`
-	URL                     string   `json:"url"`
-	IsDouble                bool     `json:"is-double"`
-	Home                    int      `json:"home"`
-	Away                    int      `json:"away"`
-	HomeName                string   `json:"home-name"`
-	AwayName                string   `json:"away-name"`
-	HomeCountryTwoChartName string   `json:"home-country-two-chart-name"`
-	AwayCountryTwoChartName string   `json:"away-country-two-chart-name"`
-	HomeParticipantID       int      `json:"home-participant-id"`
-	AwayParticipantID       int      `json:"away-participant-id"`
-	StatusID                int      `json:"status-id"`
-	EventStageID            int      `json:"event-stage-id"`
-	EventStageName          string   `json:"event-stage-name"`
-	TournamentStageID       int      `json:"tournament-stage-id"`
-	TournamentStageTypeID   int      `json:"tournament-stage-type-id"`
-	TournamentStageGroupID  int      `json:"tournament-stage-group-id"`
-	TournamentStageName     string   `json:"tournament-stage-name"`
-	SportID                 int      `json:"sport-id"`
-	Cols                    string   `json:"cols"`
-	CountryID               int      `json:"country-id"`
-	CountryName             string   `json:"country-name"`
-	CountryTwoChartName     string   `json:"country-two-chart-name"`
-	CountryType             string   `json:"country-type"`
-	TournamentID            int      `json:"tournament-id"`
-	TournamentName          string   `json:"tournament-name"`
-	TournamentURL           string   `json:"tournament-url"`
-	HomeParticipantImages   []string `json:"home-participant-images"`
-	AwayParticipantImages   []string `json:"away-participant-images"`
-	SportURLName            string   `json:"sport-url-name"`
-	Breadcrumbs             struct {
-		Sport struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"sport"`
-		Country struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"country"`
-		Tournament struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"tournament"`
-	} `json:"breadcrumbs"`
-	EncodeEventID        string `json:"encodeEventId"`
-	ColClassName         string `json:"colClassName"`
-	HomeParticipantTypes []int  `json:"homeParticipantTypes"`
-	AwayParticipantTypes []int  `json:"awayParticipantTypes"`
-	DateStartBase        int    `json:"date-start-base"`
-	DateStartTimestamp   int    `json:"date-start-timestamp"`
-	Date                 string `json:"date"`
-	Result               string `json:"result"`
-	HomeResult           string `json:"homeResult"`
-	AwayResult           string `json:"awayResult"`
-	HomeWinner           string `json:"home-winner"`
-	AwayWinner           string `json:"away-winner"`
-	Partialresult        string `json:"partialresult"`
-	BookmakersCount      int    `json:"bookmakersCount"`
-	WinnerPost           int    `json:"winner_post"`
-	BettingType          int    `json:"betting_type"`
-	Odds                 []struct {
-		AvgOdds           float64 `json:"avgOdds"`
-		BettingTypeID     int     `json:"bettingTypeId"`
-		EventID           int     `json:"eventId"`
-		MaxOdds           float64 `json:"maxOdds"`
-		OutcomeResultID   int     `json:"outcomeResultId"`
-		ScopeID           int     `json:"scopeId"`
-		OutcomeID         string  `json:"outcomeId"`
-		MaxOddsProviderID int     `json:"maxOddsProviderId"`
-		Active            bool    `json:"active"`
-	} `json:"odds"`
-	OddsData         map[string][]OddRow `json:"odds_data,omitempty"`
-	Name             string              `json:"name"`
-	ColClassNameTime string              `json:"colClassNameTime"`
+	ID                      int                 `json:"id"`
+	URL                     string              `json:"url"`
+	IsDouble                bool                `json:"is_double"`
+	Home                    int                 `json:"home"`
+	Away                    int                 `json:"away"`
+	HomeName                string              `json:"home_name"`
+	AwayName                string              `json:"away_name"`
+	HomeCanonicalName       string              `json:"home_canonical_name,omitempty"`
+	AwayCanonicalName       string              `json:"away_canonical_name,omitempty"`
+	HomeAbbreviation        string              `json:"home_abbreviation,omitempty"`
+	AwayAbbreviation        string              `json:"away_abbreviation,omitempty"`
+	HomeCountryTwoChartName string              `json:"home_country_two_chart_name"`
+	AwayCountryTwoChartName string              `json:"away_country_two_chart_name"`
+	HomeParticipantID       int                 `json:"home_participant_id"`
+	AwayParticipantID       int                 `json:"away_participant_id"`
+	StatusID                int                 `json:"status_id"`
+	EventStageID            int                 `json:"event_stage_id"`
+	EventStageName          string              `json:"event_stage_name"`
+	TournamentStageID       int                 `json:"tournament_stage_id"`
+	TournamentStageTypeID   int                 `json:"tournament_stage_type_id"`
+	TournamentStageGroupID  int                 `json:"tournament_stage_group_id"`
+	TournamentStageName     string              `json:"tournament_stage_name"`
+	SportID                 int                 `json:"sport_id"`
+	Cols                    string              `json:"cols"`
+	CountryID               int                 `json:"country_id"`
+	CountryName             string              `json:"country_name"`
+	CountryTwoChartName     string              `json:"country_two_chart_name"`
+	CountryType             string              `json:"country_type"`
+	TournamentID            int                 `json:"tournament_id"`
+	TournamentName          string              `json:"tournament_name"`
+	TournamentURL           string              `json:"tournament_url"`
+	HomeParticipantImages   []string            `json:"home_participant_images"`
+	AwayParticipantImages   []string            `json:"away_participant_images"`
+	SportURLName            string              `json:"sport_url_name"`
+	Breadcrumbs             Breadcrumbs         `json:"breadcrumbs"`
+	EncodeEventID           string              `json:"encode_event_id"`
+	ColClassName            string              `json:"col_class_name"`
+	HomeParticipantTypes    []int               `json:"home_participant_types"`
+	AwayParticipantTypes    []int               `json:"away_participant_types"`
+	DateStartBase           int                 `json:"date_start_base"`
+	DateStartTimestamp      int                 `json:"date_start_timestamp"`
+	Date                    string              `json:"date"`       // Start time, RFC 3339 in -tz
+	DateLocal               string              `json:"date_local"` // Start date in -tz, 2006-01-02
+	DateUTC                 string              `json:"date_utc"`   // Start date in UTC, 2006-01-02
+	Result                  string              `json:"result"`
+	HomeResult              string              `json:"home_result"`
+	AwayResult              string              `json:"away_result"`
+	HomeWinner              string              `json:"home_winner"`
+	AwayWinner              string              `json:"away_winner"`
+	Partialresult           string              `json:"partialresult"`
+	BookmakersCount         int                 `json:"bookmakers_count"`
+	WinnerPost              int                 `json:"winner_post"`
+	BettingType             int                 `json:"betting_type"`
+	Odds                    []OutcomeOdds       `json:"odds"`
+	OddsData                map[string][]OddRow `json:"odds_data,omitempty"`
+	MarketsSkipped          []string            `json:"markets_skipped,omitempty"` // Left out by -markets
+	MarketsFailed           []string            `json:"markets_failed,omitempty"`
+	ScrapedAt               string              `json:"scraped_at,omitempty"` // When the odds were scraped, RFC 3339 in UTC
+	Season                  string              `json:"season,omitempty"`     // Folder of the source file, set by combine
+	Source                  string              `json:"source,omitempty"`     // Source file relative to the combine root
+	Name                    string              `json:"name"`
+	ColClassNameTime        string              `json:"col_class_name_time"`
+}
+
+// RawMatch is a row of the results page as sent by OddsPortal, only used to
+// build a Match. Its tags follow the site and change with it.
+type RawMatch struct {
+	ID                      int              `json:"id"`
+	URL                     string           `json:"url"`
+	IsDouble                bool             `json:"is-double"`
+	Home                    int              `json:"home"`
+	Away                    int              `json:"away"`
+	HomeName                string           `json:"home-name"`
+	AwayName                string           `json:"away-name"`
+	HomeCountryTwoChartName string           `json:"home-country-two-chart-name"`
+	AwayCountryTwoChartName string           `json:"away-country-two-chart-name"`
+	HomeParticipantID       int              `json:"home-participant-id"`
+	AwayParticipantID       int              `json:"away-participant-id"`
+	StatusID                int              `json:"status-id"`
+	EventStageID            int              `json:"event-stage-id"`
+	EventStageName          string           `json:"event-stage-name"`
+	TournamentStageID       int              `json:"tournament-stage-id"`
+	TournamentStageTypeID   int              `json:"tournament-stage-type-id"`
+	TournamentStageGroupID  int              `json:"tournament-stage-group-id"`
+	TournamentStageName     string           `json:"tournament-stage-name"`
+	SportID                 int              `json:"sport-id"`
+	Cols                    string           `json:"cols"`
+	CountryID               int              `json:"country-id"`
+	CountryName             string           `json:"country-name"`
+	CountryTwoChartName     string           `json:"country-two-chart-name"`
+	CountryType             string           `json:"country-type"`
+	TournamentID            int              `json:"tournament-id"`
+	TournamentName          string           `json:"tournament-name"`
+	TournamentURL           string           `json:"tournament-url"`
+	HomeParticipantImages   []string         `json:"home-participant-images"`
+	AwayParticipantImages   []string         `json:"away-participant-images"`
+	SportURLName            string           `json:"sport-url-name"`
+	Breadcrumbs             Breadcrumbs      `json:"breadcrumbs"`
+	EncodeEventID           string           `json:"encodeEventId"`
+	ColClassName            string           `json:"colClassName"`
+	HomeParticipantTypes    []int            `json:"homeParticipantTypes"`
+	AwayParticipantTypes    []int            `json:"awayParticipantTypes"`
+	DateStartBase           int              `json:"date-start-base"`
+	DateStartTimestamp      int              `json:"date-start-timestamp"`
+	Result                  string           `json:"result"`
+	HomeResult              string           `json:"homeResult"`
+	AwayResult              string           `json:"awayResult"`
+	HomeWinner              string           `json:"home-winner"`
+	AwayWinner              string           `json:"away-winner"`
+	Partialresult           string           `json:"partialresult"`
+	BookmakersCount         int              `json:"bookmakersCount"`
+	WinnerPost              int              `json:"winner_post"`
+	BettingType             int              `json:"betting_type"`
+	Odds                    []RawOutcomeOdds `json:"odds"`
+	Name                    string           `json:"name"`
+	ColClassNameTime        string           `json:"colClassNameTime"`
+}
+
+type Breadcrumbs struct {
+	Sport struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"sport"`
+	Country struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"country"`
+	Tournament struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"tournament"`
+}
+
+// OutcomeOdds is the average and max odds of an outcome on the results page
+type OutcomeOdds struct {
+	AvgOdds           float64 `json:"avg_odds"`
+	BettingTypeID     int     `json:"betting_type_id"`
+	EventID           int     `json:"event_id"`
+	MaxOdds           float64 `json:"max_odds"`
+	OutcomeResultID   int     `json:"outcome_result_id"`
+	ScopeID           int     `json:"scope_id"`
+	OutcomeID         string  `json:"outcome_id"`
+	MaxOddsProviderID int     `json:"max_odds_provider_id"`
+	MaxOddsBookmaker  string  `json:"max_odds_bookmaker,omitempty"`
+	Active            bool    `json:"active"`
+}
+
+type RawOutcomeOdds struct {
+	AvgOdds           float64 `json:"avgOdds"`
+	BettingTypeID     int     `json:"bettingTypeId"`
+	EventID           int     `json:"eventId"`
+	MaxOdds           float64 `json:"maxOdds"`
+	OutcomeResultID   int     `json:"outcomeResultId"`
+	ScopeID           int     `json:"scopeId"`
+	OutcomeID         string  `json:"outcomeId"`
+	MaxOddsProviderID int     `json:"maxOddsProviderId"`
+	Active            bool    `json:"active"`
 }
 
 type CSVMatch struct {
-	ID                  int        `json:"id"`
-	URL                 string     `json:"url"`
-	HomeName            string     `json:"home-name"`
-	AwayName            string     `json:"away-name"`
-	Name                string     `json:"name"`
-	EventStageName      string     `json:"event-stage-name"`
-	TournamentStageName string     `json:"tournament-stage-name"`
-	TournamentName      string     `json:"tournament-name"`
-	Date                string     `json:"date"`
-	DateStartTimestamp  int        `json:"date-start-timestamp"`
-	Result              string     `json:"result"`
-	HomeResult          string     `json:"homeResult"`
-	AwayResult          string     `json:"awayResult"`
-	Partialresult       string     `json:"partialresult"`
-	Market              string     `json:"market"`
-	Bookmaker           string     `json:"bookmaker"`
-	Line                string     `json:"line"`
-	LineValue           string     `json:"line_value"`
-	Odd                 float64    `json:"odd"`
-	OpeningOdd          OpeningOdd `json:"opening_odd"`
-	OddsHistory         string     `json:"odds_history"`
+	RowID               int           `json:"row_id"` // Position in the file, key of the history CSV
+	ID                  int           `json:"id"`
+	URL                 string        `json:"url"`
+	HomeName            string        `json:"home-name"` // Abbreviation, original name if not in the registry
+	AwayName            string        `json:"away-name"` // Abbreviation, original name if not in the registry
+	HomeCanonicalName   string        `json:"home-canonical-name"`
+	AwayCanonicalName   string        `json:"away-canonical-name"`
+	HomeOriginalName    string        `json:"home-original-name"`
+	AwayOriginalName    string        `json:"away-original-name"`
+	Name                string        `json:"name"`
+	EventStageName      string        `json:"event-stage-name"`
+	TournamentStageName string        `json:"tournament-stage-name"`
+	TournamentName      string        `json:"tournament-name"`
+	Date                string        `json:"date"` // RFC 3339 in -tz
+	DateLocal           string        `json:"date_local"`
+	DateUTC             string        `json:"date_utc"`
+	DateStartTimestamp  int           `json:"date-start-timestamp"`
+	Result              string        `json:"result"`
+	HomeResult          string        `json:"homeResult"`
+	AwayResult          string        `json:"awayResult"`
+	Partialresult       string        `json:"partialresult"`
+	Market              string        `json:"market"`
+	Scope               string        `json:"scope"`
+	Bookmaker           string        `json:"bookmaker"`
+	BookmakerID         string        `json:"bookmaker_id"`
+	BookmakerType       string        `json:"bookmaker_type"` // sharp, soft, exchange
+	BookmakerRegion     string        `json:"bookmaker_region"`
+	Line                string        `json:"line"`
+	LineValue           string        `json:"line_value"`
+	Odd                 string        `json:"odd"` // In -odds-format, empty if missing
+	OpeningOddDate      string        `json:"opening_odd_date"`
+	OpeningOdd          string        `json:"opening_odd"` // In -odds-format
+	OddsHistory         []OddsHistory `json:"odds_history"`
+	Season              string        `json:"season"`
+	Source              string        `json:"source"`
 }
 
 // OddRow is the parsed odds row from the odds page
 type OddRow struct {
-	Bookmaker string     `json:"bookmaker"` // Pinnacle
-	Line      string     `json:"line"`      // 1X2, -1.5, 5.5 etc.
-	Payout    float64    `json:"payout"`    // 0.95, e.g margin
-	OddsData  []OddsData `json:"oddsData"`
+	Bookmaker   string     `json:"bookmaker"`              // Pinnacle
+	BookmakerID string     `json:"bookmaker_id,omitempty"` // pinnacle, canonical id from the bookmaker catalog
+	Scope       string     `json:"scope"`                  // FT, ML (incl. OT), P1, H1 etc.
+	Line        string     `json:"line"`                   // 1X2, -1.5, 5.5, 2:1 etc.
+	LineUnit    string     `json:"line_unit,omitempty"`    // goals, points, runs, games, sets, maps
+	Payout      float64    `json:"payout"`                 // 0.95, e.g margin
+	OddsData    []OddsData `json:"odds_data"`
 }
 
 type OddsData struct {
-	LineValue   string        `json:"lineValue"` // 1/X/2, -1.5, 5.5 etc.
-	Odd         float64       `json:"odd"`       // 1.95
-	OpeningOdd  OpeningOdd    `json:"openingOdd"`
-	OddsHistory []OddsHistory `json:"oddsHistory"` // All odds history for the specific line type
+	LineValue   string        `json:"line_value"`          // 1/X/2, -1.5, 5.5, 2:1 (correct score) etc.
+	Odd         float64       `json:"odd"`                 // 1.95, decimal
+	Missing     bool          `json:"missing,omitempty"`   // No odds for the outcome, Odd is 0
+	Formatted   string        `json:"formatted,omitempty"` // Odd in -odds-format, e.g. +150 or 19/20
+	OpeningOdd  OpeningOdd    `json:"opening_odd"`
+	OddsHistory []OddsHistory `json:"odds_history"` // All odds history for the specific line type
 }
 
-// RawOddRow is the raw data from the odds page
+// RawOddRow is the raw data of a single bookmaker row from the odds page
 type RawOddRow struct {
-	Bookmaker  string `json:"bookmaker"`  // Pinnacle
-	FirstCell  string `json:"firstCell"`  // 1.95 or -1.5 (AH etc)
-	SecondCell string `json:"secondCell"` // 3.40
-	ThirdCell  string `json:"thirdCell"`  // 3.90
-	FourthCell string `json:"fourthCell"` // 1.95, appears only for #eh;2
+	Bookmaker string   `json:"bookmaker"` // Pinnacle
+	Cells     []string `json:"cells"`     // ["-1.5", "1.95", "1.85", "94.5%"], line cells first then odds and payout
+}
+
+// OddsTable is the raw odds table of a market page
+type OddsTable struct {
+	Header []string    `json:"header"` // Outcome labels from the header row, e.g. ["1", "X", "2"]
+	Rows   []RawOddRow `json:"rows"`
 }
 
 // OpeningOdd is the opening odds for a match
@@ -139,11 +245,3 @@ type OddsHistory struct {
 	Odds   float64 `json:"odds"`   // 1.95
 	Change string  `json:"change"` // +0.05
 }
-
-// OddPageNodes is the nodes from the odds page, representing a single row of odds
-type OddPageNodes struct {
-	Bookmakers  []*cdp.Node
-	FirstCells  []*cdp.Node
-	SecondCells []*cdp.Node
-	ThirdCells  []*cdp.Node
-}
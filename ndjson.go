@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NDJSONWriter writes matches as newline delimited JSON, one match per line.
+// Appended lines are flushed and synced as soon as they are written, so a
+// crashed run loses at most the match it was working on. A truncated file is
+// an AtomicFile which replaces the old one on Close.
+type NDJSONWriter struct {
+	file   *os.File
+	atomic *AtomicFile
+	buf    *bufio.Writer
+	enc    *json.Encoder
+}
+
+// openNDJSON opens path for appending, or rewrites it with truncate. When
+// appending after a truncated last line, the new lines start on a line of
+// their own. The broken line is then skipped by readVersionedMatches.
+func openNDJSON(path string, truncate bool) (*NDJSONWriter, error) {
+	if truncate {
+		af, err := createAtomic(path, 0644)
+		if err != nil {
+			return nil, err
+		}
+		buf := bufio.NewWriter(af)
+		return &NDJSONWriter{file: af.File, atomic: af, buf: buf, enc: json.NewEncoder(buf)}, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(file)
+
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	return &NDJSONWriter{file: file, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+func (w *NDJSONWriter) Write(m *Match) error {
+	if err := w.enc.Encode(ndjsonLine{SchemaVersion: SCHEMA_VERSION, Match: m}); err != nil {
+		return fmt.Errorf("error encoding match %d: %w", m.ID, err)
+	}
+	if w.atomic != nil {
+		return nil
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+func (w *NDJSONWriter) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		if w.atomic != nil {
+			w.atomic.Close()
+		} else {
+			w.file.Close()
+		}
+		return err
+	}
+	if w.atomic != nil {
+		return w.atomic.Commit()
+	}
+	return w.file.Close()
+}
+
+// ndjsonPath is the NDJSON shard next to a page file, e.g. NHL_01.ndjson for NHL_01.json.
+func ndjsonPath(path string) string {
+	return strings.TrimSuffix(path, ".json") + ".ndjson"
+}
+
+// readMatches calls fn for every match in a JSON match file or an NDJSON
+// file, upgraded to SCHEMA_VERSION.
+func readMatches(path string, fn func(*Match) error) error {
+	return readVersionedMatches(path, func(m *Match, _ int) error {
+		return fn(m)
+	})
+}
+
+// readVersionedMatches is readMatches which also passes the schema version
+// each match was stored at. NDJSON files and journals are decoded one line at
+// a time, a line that doesn't decode (e.g. truncated by an interrupted run)
+// is logged and skipped.
+func readVersionedMatches(path string, fn func(*Match, int) error) error {
+	if !strings.HasSuffix(path, ".ndjson") && !strings.HasSuffix(path, ".journal") {
+		matches, version, err := readMatchFile(path)
+		if err != nil {
+			return err
+		}
+		for i := range matches {
+			if err := fn(&matches[i], version); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), NDJSON_MAX_LINE)
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		var v struct {
+			SchemaVersion int `json:"schema_version"`
+		}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			printLog(fmt.Sprintf("Skipping broken line %d in %s: %v", line, path, err))
+			continue
+		}
+		version := max(v.SchemaVersion, 1)
+		if version > SCHEMA_VERSION {
+			return fmt.Errorf("error decoding line %d: schema version %d is newer than %d, update the scraper", line, version, SCHEMA_VERSION)
+		}
+		m, err := upgradeMatch(raw, version)
+		if err != nil {
+			printLog(fmt.Sprintf("Skipping broken line %d in %s: %v", line, path, err))
+			continue
+		}
+		if err := fn(&m, version); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return nil
+}
+
+// scrapedIDs returns the ids of the matches with odds in an NDJSON shard,
+// which don't need to be scraped again.
+func scrapedIDs(path string) (map[int]bool, error) {
+	ids := make(map[int]bool)
+	err := readMatches(path, func(m *Match) error {
+		if len(m.OddsData) > 0 {
+			ids[m.ID] = true
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return ids, nil
+	}
+	return ids, err
+}
+
+// openShard opens the NDJSON shard of a page file when -format is ndjson,
+// along with the ids of the matches already in it. Without ndjson the shard
+// is nil and the page file is rewritten after every match as before.
+func openShard(file string) (*NDJSONWriter, map[int]bool, error) {
+	if outputFormat != "ndjson" {
+		return nil, nil, nil
+	}
+
+	path := ndjsonPath(file)
+	done, err := scrapedIDs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	w, err := openNDJSON(path, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	printLog(fmt.Sprintf("Appending scraped matches to %s, %d already done", path, len(done)))
+	return w, done, nil
+}
+
+// appendToShard writes the scraped match to the shard and drops its odds
+// from memory, they are not needed once written.
+func appendToShard(w *NDJSONWriter, m *Match) error {
+	if err := w.Write(m); err != nil {
+		return fmt.Errorf("error writing match %s to NDJSON shard: %v", m.URL, err)
+	}
+	m.OddsData = nil
+	return nil
+}
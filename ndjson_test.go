@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadMatchesSkipsBrokenLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "NHL_01.ndjson")
+
+	w, err := openNDJSON(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(&Match{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An interrupted run leaves a truncated line inside a string
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"schema_version":2,"id":2,"url":"/hockey/usa/nhl/a-b`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	w, err = openNDJSON(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(&Match{ID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	err = readMatches(path, func(m *Match) error {
+		ids = append(ids, m.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readMatches: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Errorf("read ids %v, want [1 3]", ids)
+	}
+}
+
+func TestReadMatchesRejectsNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "NHL_01.ndjson")
+	if err := os.WriteFile(path, []byte(`{"schema_version":99,"id":1}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := readMatches(path, func(*Match) error { return nil }); err == nil {
+		t.Error("readMatches accepted a newer schema version")
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// errMissingOdds is returned for cells without odds, e.g. '-' for a closed outcome
+var errMissingOdds = errors.New("missing odds")
+
+// ODDS_INPUT_FORMATS are the formats accepted by -odds-input
+var ODDS_INPUT_FORMATS = []string{"auto", "decimal", "fractional", "american", "hk"}
+
+// ODDS_OUTPUT_FORMATS are the formats accepted by -odds-format
+var ODDS_OUTPUT_FORMATS = []string{"decimal", "fractional", "american", "hk", "probability"}
+
+// parseOdds parses an odds cell into decimal odds. With the 'auto' format
+// fractional (5/2, evens), American (+150, -200) and decimal odds are told
+// apart by their notation, and values below 1 are taken as Hong Kong odds.
+// Hong Kong odds of 1 or more look exactly like decimal odds, use
+// -odds-input hk for those.
+func parseOdds(s string, format string) (float64, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "", "-", "–", "n/a", "na":
+		return 0, errMissingOdds
+	case "evs", "evens", "even":
+		return 2, nil
+	}
+
+	auto := format == "auto"
+	if auto {
+		switch {
+		case strings.Contains(s, "/"):
+			format = "fractional"
+		case strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-"):
+			format = "american"
+		default:
+			format = "decimal"
+		}
+	}
+
+	if format == "fractional" {
+		n, d, ok := strings.Cut(s, "/")
+		num, err1 := strconv.ParseFloat(n, 64)
+		den, err2 := strconv.ParseFloat(d, 64)
+		if !ok || err1 != nil || err2 != nil || den <= 0 || num < 0 {
+			return 0, fmt.Errorf("invalid fractional odds %q", s)
+		}
+		return 1 + num/den, nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid odds %q", s)
+	}
+
+	switch format {
+	case "american":
+		switch {
+		case v >= 100:
+			return 1 + v/100, nil
+		case v <= -100:
+			return 1 + 100/-v, nil
+		}
+		return 0, fmt.Errorf("invalid american odds %q", s)
+	case "hk":
+		if v <= 0 {
+			return 0, fmt.Errorf("invalid hong kong odds %q", s)
+		}
+		return 1 + v, nil
+	}
+
+	switch {
+	case auto && v > 0 && v < 1:
+		return 1 + v, nil // Hong Kong
+	case v < 1:
+		return 0, fmt.Errorf("invalid decimal odds %q", s)
+	}
+	return v, nil
+}
+
+// formatOdds formats decimal odds in the -odds-format, an empty string for
+// missing odds.
+func formatOdds(dec float64, format string) string {
+	if dec <= 1 {
+		return ""
+	}
+
+	switch format {
+	case "american":
+		if dec >= 2 {
+			return fmt.Sprintf("+%d", int(math.Round((dec-1)*100)))
+		}
+		return fmt.Sprintf("%d", -int(math.Round(100/(dec-1))))
+	case "fractional":
+		n, d := fraction(dec - 1)
+		return fmt.Sprintf("%d/%d", n, d)
+	case "hk":
+		return strconv.FormatFloat(math.Round((dec-1)*1000)/1000, 'f', -1, 64)
+	case "probability":
+		return strconv.FormatFloat(math.Round(1/dec*10000)/10000, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(dec, 'f', -1, 64)
+}
+
+// fraction returns the closest fraction to x with a denominator of at most
+// 100, the smallest denominator wins ties, e.g. 1.5 is 3/2.
+func fraction(x float64) (int, int) {
+	bestN, bestD, bestErr := int(math.Round(x)), 1, math.Inf(1)
+	for d := 1; d <= 100; d++ {
+		n := int(math.Round(x * float64(d)))
+		if e := math.Abs(x - float64(n)/float64(d)); e < bestErr-1e-9 {
+			bestN, bestD, bestErr = n, d, e
+		}
+	}
+	return bestN, bestD
+}
+
+// formatMatchOdds fills the formatted odds of every outcome in the match.
+func formatMatchOdds(m *Match) {
+	for market := range m.OddsData {
+		for i := range m.OddsData[market] {
+			for j := range m.OddsData[market][i].OddsData {
+				od := &m.OddsData[market][i].OddsData[j]
+				od.Formatted = ""
+				if oddsFormat != "decimal" {
+					od.Formatted = formatOdds(od.Odd, oddsFormat)
+				}
+			}
+		}
+	}
+}
@@ -4,15 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"os"
 	"path/filepath"
-	"slices"
 	"strings"
 	"time"
 
 	"github.com/chromedp/cdproto/cdp"
-	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
 )
 
@@ -109,298 +106,188 @@ func hoverOverCell(xPath string, waitTooltip bool) chromedp.ActionFunc {
 	})
 }
 
-func scrapeOddPageNodes(o *OddPageNodes) chromedp.ActionFunc {
+// scrapeOddsTable reads the header labels and every bookmaker row of the open
+// odds table in one go, whatever the number of columns.
+func scrapeOddsTable(t *OddsTable, s *string) chromedp.ActionFunc {
 	return chromedp.ActionFunc(func(ctx context.Context) error {
-		s := parseURLSuffix(url)
-		if slices.Contains(DONT_SCRAPE, s) {
-			printDebug(fmt.Sprintf("Skipping suffix %s", s))
-			return nil
-		}
-
-		printDebug("Scraping odd page nodes")
-		err := retry(func() error {
-			return chromedp.Nodes(`
-				div[data-v-0e9f6ffa].border-black-borders.flex.h-9 > div:first-child
-			`, &o.Bookmakers).Do(ctx)
-		})
-		if err != nil {
-			return fmt.Errorf("error getting bookmakers: %v", err)
-		}
-
-		err = retry(func() error {
-			return chromedp.Nodes(`
-				div[data-v-0e9f6ffa].border-black-borders.flex.h-9 > div:nth-child(2)
-			`, &o.FirstCells).Do(ctx)
-		})
-		if err != nil {
-			return fmt.Errorf("error getting first cells: %v", err)
-		}
+		*s = parseURLSuffix(url)
 
-		err = retry(func() error {
-			return chromedp.Nodes(`
-				div[data-v-0e9f6ffa].border-black-borders.flex.h-9 > div:nth-child(3)
-			`, &o.SecondCells).Do(ctx)
-		})
+		printDebug("Scraping odds table")
+		err := chromedp.WaitReady(ODDS_ROWS).Do(ctx)
 		if err != nil {
-			return fmt.Errorf("error getting second cells: %v", err)
+			return fmt.Errorf("error waiting for odds rows: %v", err)
 		}
 
-		err = retry(func() error {
-			return chromedp.Nodes(`
-				div[data-v-0e9f6ffa].border-black-borders.flex.h-9 > div:nth-child(4)
-			`, &o.ThirdCells).Do(ctx)
-		})
+		err = chromedp.Evaluate(fmt.Sprintf(ODDS_TABLE_JS, bookmakerFilter.js()), t).Do(ctx)
 		if err != nil {
-			return fmt.Errorf("error getting third cells: %v", err)
+			return fmt.Errorf("error reading odds table: %v", err)
 		}
 
-		printDebug("Scraped odd page nodes")
+		printDebug(fmt.Sprintf("Scraped odds table with header %v and %d rows", t.Header, len(t.Rows)))
 		return nil
 	})
 }
 
-func scrapeOUorAH(r *RawOddRow, row int) chromedp.ActionFunc {
-	return chromedp.ActionFunc(func(ctx context.Context) error {
-		// suf := parseURLSuffix(url)
-
-		printDebug(fmt.Sprintf("Scraping OU or AH for row %d...", row))
-
-		var n []*cdp.Node
-		err := retry(func() error {
-			return chromedp.Nodes(BOOKMAKER_CELL_TC, &n).Do(ctx)
-		})
-		if err != nil {
-			return fmt.Errorf("error getting bookmakers: %v", err)
-		}
-		err = retry(func() error {
-			return chromedp.Text(n[row].FullXPath(), &r.Bookmaker).Do(ctx)
-		})
-		if err != nil {
-			return fmt.Errorf("error getting bookmakers: %v", err)
-		}
-
-		err = retry(func() error {
-			return chromedp.Nodes(FIRST_CELL_TC, &n).Do(ctx)
-		})
-		if err != nil {
-			return fmt.Errorf("error getting odds: %v", err)
-		}
-		err = retry(func() error {
-			return chromedp.Text(n[row].FullXPath(), &r.FirstCell).Do(ctx)
-		})
-		printDebug(fmt.Sprintf("Scraped first cell for row %d, got %s", row, r.FirstCell))
-		if err != nil {
-			return fmt.Errorf("error getting odds: %v", err)
+// openMarket clicks the market tab, hidden tabs are behind MORE_BUTTON.
+func openMarket(btn *cdp.Node, mode string) chromedp.Tasks {
+	if mode == "hidden" {
+		return chromedp.Tasks{
+			hoverOverCell(MORE_BUTTON, false),
+			clickButton(btn),
 		}
+	}
+	return chromedp.Tasks{clickButton(btn)}
+}
 
-		err = retry(func() error {
-			return chromedp.Nodes(SECOND_CELL_TC, &n).Do(ctx)
-		})
-		if err != nil {
-			return fmt.Errorf("error getting odds: %v", err)
-		}
+// clickScope switches to the scope tab with the given label and updates the
+// location, as the scope is part of the URL suffix.
+func clickScope(label string) chromedp.ActionFunc {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		printDebug(fmt.Sprintf("Clicking scope tab %s", label))
 
-		err = retry(func() error {
-			return chromedp.Text(n[row].FullXPath(), &r.SecondCell).Do(ctx)
-		})
+		err := chromedp.Evaluate(fmt.Sprintf(SCOPE_TAB_CLICK, label), nil).Do(ctx)
 		if err != nil {
-			return fmt.Errorf("error getting odds: %v", err)
+			return fmt.Errorf("error clicking scope tab %s: %v", label, err)
 		}
 
-		err = retry(func() error {
-			return chromedp.Nodes(THIRD_CELL_TC, &n).Do(ctx)
-		})
+		err = chromedp.Sleep(SCOPE_SWITCH_WAIT).Do(ctx)
 		if err != nil {
-			return fmt.Errorf("error getting odds: %v", err)
+			return err
 		}
 
-		err = retry(func() error {
-			return chromedp.Text(n[row].FullXPath(), &r.ThirdCell).Do(ctx)
-		})
+		err = chromedp.Location(&url).Do(ctx)
 		if err != nil {
-			return fmt.Errorf("error getting odds: %v", err)
+			return fmt.Errorf("error getting location: %v", err)
 		}
-
 		return nil
 	})
 }
 
-func scrapeOddPageRow(r *RawOddRow, row int, url string) chromedp.ActionFunc {
-	return chromedp.ActionFunc(func(ctx context.Context) error {
-		printDebug(fmt.Sprintf("Scraping odd page row %d...", row))
-		suf := parseURLSuffix(url)
-		var err error
-		if suf == "#over-under;1" || suf == "#over-under;2" || suf == "#ah;1" || suf == "#ah;2" {
-			err = scrapeOUorAH(r, row).Do(ctx)
-		} else {
-			err = retry(func() error {
-				return chromedp.Text(fmt.Sprintf(BOOKMAKER_CELL, row+2), &r.Bookmaker).Do(ctx)
-			})
-			if err != nil {
-				return fmt.Errorf("error getting bookmakers: %v", err)
-			}
+// scrapeMarket opens the market tab and scrapes the odds table of every scope
+// tab in it (Full Time, 1st Period, 1st Half etc.) into oddsData. Markets and
+// scopes not selected with -markets are skipped without opening them.
+func scrapeMarket(ctx context.Context, btn *cdp.Node, mode string, sp *SportProfile, oddsData map[string][]OddRow, report *MarketReport) error {
+	var tab string
+	err := chromedp.Run(ctx, chromedp.TextContent([]cdp.NodeID{btn.NodeID}, &tab, chromedp.ByNodeID))
+	if err != nil {
+		printDebug(fmt.Sprintf("Error reading market tab label: %v", err))
+	}
+	tab = strings.TrimSpace(tab)
 
-			printDebug(fmt.Sprintf("Scraped bookmaker for row %d, got %s", row, r.Bookmaker))
+	suffix := marketSuffix(tab)
+	if suffix != "" && !marketFilter.WantsMarket(suffix) {
+		report.skip(parseLineValue(suffix, ""))
+		return nil
+	}
+	// marketKey is the key of a scope before the table is read, the tab label
+	// if we don't know the market
+	marketKey := func(label string) string {
+		if suffix == "" {
+			return strings.TrimSpace(tab + " " + label)
+		}
+		return parseLineValue(suffix, parseScope(label, ""))
+	}
 
-			if isThreeColumn(suf) || suf == "#home-away;1" || suf == "#home-away;2" || suf == "#bts;2" || suf == "#dnb;2" {
-				err = retry(func() error {
-					return chromedp.Text(fmt.Sprintf(FIRST_CELL, row+2), &r.FirstCell).Do(ctx)
-				})
-				printDebug(fmt.Sprintf("Scraped first cell for row %d, got %s", row, r.FirstCell))
-				if err != nil {
-					return fmt.Errorf("error getting line: %v", err)
-				}
-			} else {
-				if suf != "#over-under;1" && suf != "#over-under;2" && suf != "#ah;1" && suf != "#ah;2" {
-					var n []*cdp.Node
-					err = retry(func() error {
-						return chromedp.Nodes(FIRST_CELL_TC, &n).Do(ctx)
-					})
-					if err != nil {
-						return fmt.Errorf("error getting odds: %v", err)
-					}
-					err = retry(func() error {
-						return chromedp.Text(n[row].FullXPath(), &r.FirstCell).Do(ctx)
-					})
-					printDebug(fmt.Sprintf("Scraped first cell for row %d, got %s", row, r.FirstCell))
-					if err != nil {
-						return fmt.Errorf("error getting odds: %v", err)
-					}
-				}
-			}
+	err = chromedp.Run(ctx, openMarket(btn, mode))
+	if err != nil {
+		report.fail(marketKey(""))
+		return err
+	}
 
-			err = retry(func() error {
-				return chromedp.Text(fmt.Sprintf(SECOND_CELL, row+2), &r.SecondCell).Do(ctx)
-			})
-			printDebug(fmt.Sprintf("Scraped second cell for row %d, got %s", row, r.SecondCell))
-			if err != nil {
-				return fmt.Errorf("error getting odds: %v", err)
-			}
+	var labels []string
+	err = chromedp.Run(ctx, chromedp.Evaluate(SCOPE_TAB_LABELS, &labels))
+	if err != nil {
+		printLog(fmt.Sprintf("Error getting scope tabs: %v", err))
+	}
+	if len(labels) == 0 {
+		// No scope tabs, only the table that is already open
+		labels = []string{""}
+	}
 
-			// Process third cell if it exists
-			if suf != "#home-away;1" && suf != "#home-away;2" && suf != "#bts;2" && suf != "#dnb;2" {
-				err = retry(func() error {
-					return chromedp.Text(fmt.Sprintf(THIRD_CELL, row+2), &r.ThirdCell).Do(ctx)
-				})
-				printDebug(fmt.Sprintf("Scraped third cell for row %d, got %s", row, r.ThirdCell))
+	for _, label := range labels {
+		var t OddsTable
+		var s string
+
+		if label != "" {
+			if suffix != "" && !marketFilter.WantsKey(marketKey(label)) {
+				report.skip(marketKey(label))
+				continue
+			}
+			err = chromedp.Run(ctx, clickScope(label))
+			if err != nil {
+				printLog(fmt.Sprintf("Error opening scope %q: %v", label, err))
+				report.fail(marketKey(label))
+				continue
 			}
 		}
+
+		scope := parseScope(label, parseURLSuffix(url))
+		err = chromedp.Run(ctx,
+			expandAllSections(),
+			scrapeOddsTable(&t, &s),
+		)
 		if err != nil {
-			return fmt.Errorf("error getting odds: %v", err)
+			printLog(fmt.Sprintf("Error scraping %s scope %q: %v", parseURLSuffix(url), label, err))
+			report.fail(marketKey(label))
+			continue
 		}
 
-		printDebug(fmt.Sprintf("Scraped values: Bookmaker: %s, 1st: %s, 2nd: %s, 3rd: %s, 4th: %s\n", r.Bookmaker, r.FirstCell, r.SecondCell, r.ThirdCell, r.FourthCell))
-		return nil
-	})
-}
-
-func scrapeOddPageRows(rows *[]OddRow, nodes *OddPageNodes, s *string) chromedp.ActionFunc {
-	return chromedp.ActionFunc(func(ctx context.Context) error {
-		*s = parseURLSuffix(url)
-
-		if slices.Contains(DONT_SCRAPE, *s) {
-			printDebug(fmt.Sprintf("Skipping suffix %s", *s))
-			return nil
+		key := parseLineValue(s, scope)
+		if !marketFilter.WantsKey(key) {
+			report.skip(key)
+			continue
 		}
 
-		for i := 0; i < len(nodes.Bookmakers); i++ {
-			printDebug(fmt.Sprintf("Scraping odds row %d/%d", i+1, len(nodes.Bookmakers)))
-			var rRow RawOddRow
-			err := chromedp.Run(ctx,
-				scrapeOddPageRow(&rRow, i, url),
-			)
-			if err != nil {
-				return err
-			}
-
-			if strictMode && !isWantedBookmaker(rRow.Bookmaker) {
-				continue
-			}
-
-			o := parseRowData(&rRow, *s)
+		if market, _ := splitSuffix(s); !sp.HasMarket(market) {
+			printLog(fmt.Sprintf("Market %s is not in the %s profile, parsing it generically", market, sp.Name))
+		}
 
-			if *s == "OU-FT" || *s == "AH-FT" || *s == "OU-ML" || *s == "AH-ML" {
-				o.OddsData[0].LineValue = "1"
-				o.OddsData[1].LineValue = "2"
-			}
-			*rows = append(*rows, o)
+		o := parseOddsTable(&t, s, scope, sp)
+		if o != nil {
+			printDebug(fmt.Sprintf("Scraped %d rows for %s", len(o), key))
+			oddsData[key] = append(oddsData[key], o...)
 		}
+	}
 
-		return nil
-	})
+	return nil
 }
 
-func scrapeURL(ctx context.Context, btn *cdp.Node, mode string) ([]OddRow, string, error) {
-	var err error
-	var o []OddRow
-	var nodes OddPageNodes
-	var s string
-	if mode == "hidden" {
-		err = chromedp.Run(ctx,
-			hoverOverCell(MORE_BUTTON, false),
-			clickButton(btn),
-			expandAllSections(),
-			scrapeOddPageNodes(&nodes),
-			scrapeOddPageRows(&o, &nodes, &s),
-		)
-	} else if mode == "subpage" {
-		err = chromedp.Run(ctx,
-			expandAllSections(),
-			scrapeOddPageNodes(&nodes),
-			scrapeOddPageRows(&o, &nodes, &s),
-		)
-	} else {
-		err = chromedp.Run(ctx,
-			clickButton(btn),
-			expandAllSections(),
-			scrapeOddPageNodes(&nodes),
-			scrapeOddPageRows(&o, &nodes, &s),
-		)
-	}
+// scrapeOdds scrapes all markets for the match in the shared browser session
+// through the next proxy from the pool and reports the outcome back to it.
+func scrapeOdds(url string, sp *SportProfile) (map[string][]OddRow, *MarketReport, error) {
+	report := &MarketReport{}
+	p := proxies.Next()
+	ctx, err := session.Acquire(p)
 	if err != nil {
-		return o, parseLineValue(s), err
+		proxies.Report(p, false)
+		return nil, report, err
 	}
-	return o, parseLineValue(s), nil
-}
 
-func scrapeOdds(url string) (map[string][]OddRow, error) {
-	printLog(fmt.Sprintf("Starting to scrape odds for URL: %s", url))
+	var w throttleWatch
+	oddsData, err := scrapeOddsIn(ctx, url, sp, &w, report)
+	session.Release(err)
+	proxies.Report(p, !proxyFailed(err, &w))
 
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", !isDebug),
-		chromedp.Flag("disable-gpu", !isDebug),
-	)
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancel()
+	if len(report.Skipped) > 0 || len(report.Failed) > 0 {
+		printLog(fmt.Sprintf("Markets skipped: %v, failed: %v", report.Skipped, report.Failed))
+	}
+	return oddsData, report, err
+}
 
-	ctx, cancel := chromedp.NewContext(allocCtx)
-	defer cancel()
+func scrapeOddsIn(ctx context.Context, url string, sp *SportProfile, w *throttleWatch, report *MarketReport) (map[string][]OddRow, error) {
+	printLog(fmt.Sprintf("Starting to scrape %s odds for URL: %s", sp.Name, url))
 
 	if !isDebug {
+		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, MAX_SLEEP*time.Minute)
 		defer cancel()
 	}
 
-	// Add rate limit handling
-	var gotResponse bool
-	chromedp.ListenTarget(ctx, func(ev interface{}) {
-		if ev, ok := ev.(*network.EventResponseReceived); ok {
-			if ev.Response.Status == 429 {
-				gotResponse = true
-				printLog("Received HTTP 429 - Too Many Requests. Waiting before retry...")
-				time.Sleep(15*time.Second + time.Duration(rand.Intn(15))*time.Second)
-			}
-		}
-	})
+	w.listen(ctx)
 
-	err := chromedp.Run(ctx,
-		network.Enable(),
-		network.SetExtraHTTPHeaders(HEADERS),
-		chromedp.Navigate(url),
-	)
-	if err != nil || gotResponse {
-		printLog(fmt.Sprintf("Error navigating to page: %v", err))
-		return scrapeOdds(url) // Recursive retry
+	err := navigate(ctx, w, url)
+	if err != nil {
+		return nil, fmt.Errorf("error navigating to page: %v", err)
 	}
 
 	var lineButtons []*cdp.Node
@@ -414,59 +301,9 @@ func scrapeOdds(url string) (map[string][]OddRow, error) {
 
 	oddsData := make(map[string][]OddRow)
 	for _, b := range lineButtons {
-		o, s, err := scrapeURL(ctx, b, "visible")
+		err := scrapeMarket(ctx, b, "visible", sp, oddsData, report)
 		if err != nil {
 			printLog(fmt.Sprintf("Error scraping URL %s: %v", url, err))
-			// return nil, err
-		}
-
-		if o != nil {
-			if s == "OU-ML" || s == "AH-ML" {
-				for j := range o {
-					o[j].OddsData[0].LineValue = "1"
-					o[j].OddsData[1].LineValue = "2"
-				}
-			}
-			oddsData[s] = append(oddsData[s], o...)
-		}
-
-		// SUBPAGE LOGIC
-
-		if s == "OU-ML" || s == "AH-ML" {
-			// Check if there is a subpage for this line
-			printDebug(fmt.Sprintf("Checking for subpage button for %s\n", s))
-			var subpageBtn []*cdp.Node
-			err = chromedp.Run(ctx, chromedp.Evaluate(FT_LINE_BUTTON, &subpageBtn))
-			if err != nil {
-				printLog(fmt.Sprintf("Error navigating to subpage: %v", err))
-				// continue
-			}
-
-			// Get the location of the subpage button
-			var loc string
-			err = chromedp.Run(ctx, chromedp.Location(&loc))
-			if err != nil {
-				printLog(fmt.Sprintf("Error getting location: %v", err))
-				// continue
-			}
-			printDebug(fmt.Sprintf("\t\tLocation: %s\n", loc))
-			lv := parseLineValue(parseURLSuffix(loc))
-
-			// Scrape subpage
-			od, _, err := scrapeURL(ctx, b, "subpage")
-			if err != nil {
-				printLog(fmt.Sprintf("Error scraping subpage: %v", err))
-				// continue
-			}
-
-			if od != nil {
-				for j := range od {
-					od[j].OddsData[0].LineValue = "1"
-					od[j].OddsData[1].LineValue = "2"
-				}
-				printDebug(fmt.Sprintf("\t\tScraped subpage %s..., \t %+v\n", lv, od[0]))
-				oddsData[lv] = append(oddsData[lv], od...)
-			}
 		}
 	}
 
@@ -488,62 +325,22 @@ func scrapeOdds(url string) (map[string][]OddRow, error) {
 
 		if len(hiddenLineButtons) > 0 {
 			for _, b := range hiddenLineButtons[:len(hiddenLineButtons)-1] {
-				o, s, err := scrapeURL(ctx, b, "hidden")
+				err := scrapeMarket(ctx, b, "hidden", sp, oddsData, report)
 				if err != nil {
 					return nil, err
 				}
-
-				if o != nil {
-					oddsData[s] = append(oddsData[s], o...)
-				}
-
-				// SUBPAGE LOGIC
-
-				if s == "OU-ML" || s == "OU-FT" {
-					// Check if there is a subpage for this line
-					printDebug(fmt.Sprintf("Checking for subpage button for %s\n", s))
-					var subpageBtn []*cdp.Node
-					err = chromedp.Run(ctx, chromedp.Evaluate(FT_LINE_BUTTON, &subpageBtn))
-					if err != nil {
-						printLog(fmt.Sprintf("Error navigating to subpage: %v", err))
-						continue
-					}
-
-					// Get the location of the subpage button
-					var loc string
-					err = chromedp.Run(ctx, chromedp.Location(&loc))
-					if err != nil {
-						printLog(fmt.Sprintf("Error getting location: %v", err))
-						continue
-					}
-					printDebug(fmt.Sprintf("\t\tLocation: %s\n", loc))
-					lv := parseLineValue(parseURLSuffix(loc))
-
-					// Scrape subpage
-					od, _, err := scrapeURL(ctx, b, "subpage")
-					if err != nil {
-						return nil, err
-					}
-
-					if od != nil {
-						for j := range od {
-							od[j].OddsData[0].LineValue = "1"
-							od[j].OddsData[1].LineValue = "2"
-						}
-						printDebug(fmt.Sprintf("\t\tScraped subpage %s..., \t %+v\n", lv, od[0]))
-						oddsData[lv] = append(oddsData[lv], od...)
-					}
-				}
 			}
 		}
 	}
 
+	bookmakerFilter.Top(oddsData)
+
 	printLog(fmt.Sprintf("Successfully scraped and saved odds for URL: %s", url))
 	return oddsData, nil
 }
 
 func runMatch(url string) {
-	oddsData, err := scrapeOdds(url)
+	oddsData, _, err := scrapeOdds(url, sportFromURL(url))
 	if err != nil {
 		printLog(fmt.Sprintf("Error scraping odds: %v", err))
 	}
@@ -553,7 +350,7 @@ func runMatch(url string) {
 		printLog(fmt.Sprintf("Error marshaling scraped data to JSON: %v", err))
 	}
 
-	err = os.WriteFile(saveAs, data, 0644)
+	err = writeFileAtomic(saveAs, data, 0644)
 	if err != nil {
 		printLog(fmt.Sprintf("Error writing scraped data to file: %v", err))
 	}
@@ -580,45 +377,58 @@ func runMatchFull() {
 		file := filepath.Join(path, f.Name())
 		printLog(fmt.Sprintf("Processing file %d/%d: %s", i+1, len(files), file))
 
-		data, err := os.ReadFile(file)
+		matches, err := loadPage(file)
 		if err != nil {
-			printLog(fmt.Sprintf("Error reading file %s: %v", file, err))
+			printLog(fmt.Sprintf("Error reading matches from file %s: %v", file, err))
 			continue
 		}
 
-		var matches []Match
-		err = json.Unmarshal(data, &matches)
+		shard, done, err := openShard(file)
 		if err != nil {
-			printLog(fmt.Sprintf("Error unmarshaling JSON from file %s: %v", file, err))
+			printLog(fmt.Sprintf("Error opening NDJSON shard for %s: %v", file, err))
 			continue
 		}
+		var journal *NDJSONWriter
+		if shard == nil {
+			if journal, err = openJournal(file); err != nil {
+				printLog(fmt.Sprintf("Error opening journal for %s: %v", file, err))
+				continue
+			}
+		}
+		saved := true
 
 		for j := range matches {
 			printLog(fmt.Sprintf("Scraping odds for match %d/%d in file %s", j+1, len(matches), file))
-			if len(matches[j].OddsData) > 0 {
+			if len(matches[j].OddsData) > 0 || done[matches[j].ID] {
 				printLog(fmt.Sprintf("Odds data already exists for match %s, skipping", matches[j].URL))
 				continue
 			}
 
-			oddsData, err := scrapeOdds(BASEURL + matches[j].URL)
+			oddsData, report, err := scrapeOdds(BASEURL+matches[j].URL, matchSport(&matches[j]))
 			if err != nil {
 				printLog(fmt.Sprintf("Error scraping odds for %s: %v", BASEURL+matches[j].URL, err))
 			}
 			matches[j].OddsData = oddsData
-			matches[j].Date = parseMatchDate(int64(matches[j].DateStartTimestamp))
-
-			// Save after each match
-			updatedData, err := json.MarshalIndent(matches, "", "  ")
-			if err != nil {
-				printLog(fmt.Sprintf("Warning: Failed to marshal with indentation after match %d: %v. Trying without indentation...", j+1, err))
-				updatedData, err = json.Marshal(matches)
-				if err != nil {
-					printLog(fmt.Sprintf("Error: Failed to marshal data even without indentation after match %d: %v", j+1, err))
-					continue
+			matches[j].MarketsSkipped = report.Skipped
+			matches[j].MarketsFailed = report.Failed
+			matches[j].ScrapedAt = time.Now().UTC().Format(time.RFC3339)
+			setMatchDates(&matches[j])
+
+			if shard != nil {
+				if err == nil {
+					if err := appendToShard(shard, &matches[j]); err != nil {
+						printLog(err.Error())
+						continue
+					}
+					printLog(fmt.Sprintf("Successfully saved progress after match %d/%d", j+1, len(matches)))
 				}
+				microSleep()
+				continue
 			}
 
-			err = os.WriteFile(file, updatedData, 0644)
+			// Save after each match
+			err = savePage(file, journal, matches, &matches[j])
+			saved = err == nil
 			if err != nil {
 				printLog(fmt.Sprintf("Error writing updated data to file after match %d: %v", j+1, err))
 				continue
@@ -628,6 +438,11 @@ func runMatchFull() {
 			microSleep()
 		}
 
+		if shard != nil {
+			shard.Close()
+		} else {
+			closeJournal(journal, file, saved)
+		}
 		printLog(fmt.Sprintf("Successfully processed file %d/%d: %s", i+1, len(files), file))
 	}
 
@@ -638,50 +453,67 @@ func runMatchFullDaily() {
 	printLog(fmt.Sprintf("Processing file %s", saveAs+"01.json"))
 
 	// Read the file
-	data, err := os.ReadFile(saveAs + "01.json")
+	matches, err := loadPage(saveAs + "01.json")
 	if err != nil {
-		printLog(fmt.Sprintf("Error reading file %s: %v", saveAs+"01.json", err))
+		printLog(fmt.Sprintf("Error reading matches from file %s: %v", saveAs+"01.json", err))
 		return
 	}
 
-	// Unmarshal JSON data
-	var matches []Match
-	err = json.Unmarshal(data, &matches)
+	// Filter matches within two days
+	matches = filterMatches(matches)
+	for j := range matches {
+		setMatchDates(&matches[j])
+	}
+
+	shard, done, err := openShard(saveAs + "01.json")
 	if err != nil {
-		printLog(fmt.Sprintf("Error unmarshaling JSON from file %s: %v", saveAs+"01.json", err))
+		printLog(fmt.Sprintf("Error opening NDJSON shard for %s: %v", saveAs+"01.json", err))
 		return
 	}
-
-	// Filter matches within two days
-	matches = filterMatches(matches)
+	var journal *NDJSONWriter
+	saved := true
+	if shard != nil {
+		defer shard.Close()
+	} else {
+		journal, err = openJournal(saveAs + "01.json")
+		if err != nil {
+			printLog(fmt.Sprintf("Error opening journal for %s: %v", saveAs+"01.json", err))
+			return
+		}
+		defer func() { closeJournal(journal, saveAs+"01.json", saved) }()
+	}
 
 	// Process each match
 	for j := range matches {
 		printLog(fmt.Sprintf("Scraping odds for match %d/%d in file %s", j+1, len(matches), saveAs+"01.json"))
-		if len(matches[j].OddsData) > 0 {
+		if len(matches[j].OddsData) > 0 || done[matches[j].ID] {
 			printLog(fmt.Sprintf("Odds data already exists for match %s, skipping", matches[j].URL))
 			continue
 		}
 
-		oddsData, err := scrapeOdds(BASEURL + matches[j].URL)
+		oddsData, report, err := scrapeOdds(BASEURL+matches[j].URL, matchSport(&matches[j]))
 		if err != nil {
 			printLog(fmt.Sprintf("Error scraping odds for %s: %v", BASEURL+matches[j].URL, err))
 			continue
 		}
 		matches[j].OddsData = oddsData
+		matches[j].MarketsSkipped = report.Skipped
+		matches[j].MarketsFailed = report.Failed
+		matches[j].ScrapedAt = time.Now().UTC().Format(time.RFC3339)
 
-		// Save after each match
-		updatedData, err := json.MarshalIndent(matches, "", "  ")
-		if err != nil {
-			printLog(fmt.Sprintf("Warning: Failed to marshal with indentation after match %d: %v. Trying without indentation...", j+1, err))
-			updatedData, err = json.Marshal(matches)
-			if err != nil {
-				printLog(fmt.Sprintf("Error: Failed to marshal data even without indentation after match %d: %v", j+1, err))
+		if shard != nil {
+			if err := appendToShard(shard, &matches[j]); err != nil {
+				printLog(err.Error())
 				continue
 			}
+			printLog(fmt.Sprintf("Successfully saved progress after match %d/%d", j+1, len(matches)))
+			microSleep()
+			continue
 		}
 
-		err = os.WriteFile(saveAs+"01.json", updatedData, 0644)
+		// Save after each match
+		err = savePage(saveAs+"01.json", journal, matches, &matches[j])
+		saved = err == nil
 		if err != nil {
 			printLog(fmt.Sprintf("Error writing updated data to file after match %d: %v", j+1, err))
 			continue
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// errInvalid stands for any error other than errMissingOdds in the tests
+var errInvalid = errors.New("invalid odds")
+
+func TestParseOdds(t *testing.T) {
+	tests := []struct {
+		in      string
+		format  string
+		want    float64
+		wantErr error // errMissingOdds, or errInvalid for any other error
+	}{
+		{"2.50", "decimal", 2.5, nil},
+		{"2.1", "auto", 2.1, nil},
+		{" 1.91 ", "auto", 1.91, nil},
+		{"5/2", "auto", 3.5, nil},
+		{"1/2", "fractional", 1.5, nil},
+		{"EVS", "auto", 2, nil},
+		{"evens", "decimal", 2, nil},
+		{"+150", "auto", 2.5, nil},
+		{"-200", "auto", 1.5, nil},
+		{"100", "american", 2, nil},
+		{"0.85", "auto", 1.85, nil},
+		{"0.85", "hk", 1.85, nil},
+		{"1.2", "hk", 2.2, nil},
+		{"", "auto", 0, errMissingOdds},
+		{"-", "auto", 0, errMissingOdds},
+		{"N/A", "decimal", 0, errMissingOdds},
+		{"+50", "american", 0, errInvalid},
+		{"0.5", "decimal", 0, errInvalid},
+		{"0", "hk", 0, errInvalid},
+		{"5/0", "fractional", 0, errInvalid},
+		{"5-2", "fractional", 0, errInvalid},
+		{"abc", "auto", 0, errInvalid},
+	}
+	for _, tt := range tests {
+		got, err := parseOdds(tt.in, tt.format)
+		switch {
+		case tt.wantErr == nil && err != nil:
+			t.Errorf("parseOdds(%q, %s) error %v", tt.in, tt.format, err)
+		case tt.wantErr == errMissingOdds && !errors.Is(err, errMissingOdds):
+			t.Errorf("parseOdds(%q, %s) error %v, want errMissingOdds", tt.in, tt.format, err)
+		case tt.wantErr == errInvalid && (err == nil || errors.Is(err, errMissingOdds)):
+			t.Errorf("parseOdds(%q, %s) error %v, want an invalid odds error", tt.in, tt.format, err)
+		case math.Abs(got-tt.want) > 1e-9:
+			t.Errorf("parseOdds(%q, %s) = %v, want %v", tt.in, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestFormatOdds(t *testing.T) {
+	tests := []struct {
+		dec    float64
+		format string
+		want   string
+	}{
+		{2.5, "decimal", "2.5"},
+		{2.5, "american", "+150"},
+		{2, "american", "+100"},
+		{1.5, "american", "-200"},
+		{1.909, "american", "-110"},
+		{3.5, "fractional", "5/2"},
+		{1.5, "fractional", "1/2"},
+		{2, "fractional", "1/1"},
+		{1.909, "fractional", "10/11"},
+		{1.85, "hk", "0.85"},
+		{2, "probability", "0.5"},
+		{3, "probability", "0.3333"},
+		{1, "decimal", ""},
+		{0, "american", ""},
+	}
+	for _, tt := range tests {
+		if got := formatOdds(tt.dec, tt.format); got != tt.want {
+			t.Errorf("formatOdds(%v, %s) = %q, want %q", tt.dec, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestFraction(t *testing.T) {
+	tests := []struct {
+		x    float64
+		n, d int
+	}{
+		{0.5, 1, 2},
+		{2.5, 5, 2},
+		{0.25, 1, 4}, // the smallest denominator wins, not 2/8
+		{1.0 / 3, 1, 3},
+		{0.3333, 1, 3},
+		{0.909, 10, 11}, // rounds to the closest, not 91/100
+		{4, 4, 1},
+		{3.14159, 311, 99},
+		{0.004, 0, 1},
+	}
+	for _, tt := range tests {
+		if n, d := fraction(tt.x); n != tt.n || d != tt.d {
+			t.Errorf("fraction(%v) = %d/%d, want %d/%d", tt.x, n, d, tt.n, tt.d)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+)
+
+// ParquetRow is one outcome of one bookmaker's line, the same grain as the
+// CSV but with typed columns. Missing odds are null.
+type ParquetRow struct {
+	ID                  int64                `parquet:"oddsportal_id"`
+	URL                 string               `parquet:"url"`
+	HomeTeam            string               `parquet:"home_team"`
+	AwayTeam            string               `parquet:"away_team"`
+	HomeCanonical       string               `parquet:"home_canonical"`
+	AwayCanonical       string               `parquet:"away_canonical"`
+	HomeOriginal        string               `parquet:"home_original"`
+	AwayOriginal        string               `parquet:"away_original"`
+	Name                string               `parquet:"name"`
+	EventStageName      string               `parquet:"event_stage_name"`
+	TournamentStageName string               `parquet:"tournament_stage_name"`
+	TournamentName      string               `parquet:"tournament_name"`
+	StartTime           time.Time            `parquet:"start_time,timestamp(millisecond:utc)"`
+	LocalDate           string               `parquet:"local_date"`
+	UTCDate             string               `parquet:"utc_date"`
+	Result              string               `parquet:"result"`
+	HomeResult          string               `parquet:"home_result"`
+	AwayResult          string               `parquet:"away_result"`
+	Partialresult       string               `parquet:"partial_result"`
+	Market              string               `parquet:"market"`
+	Scope               string               `parquet:"scope"`
+	Bookmaker           string               `parquet:"bookmaker"`
+	BookmakerID         string               `parquet:"bookmaker_id"`
+	BookmakerType       string               `parquet:"bookmaker_type"`
+	BookmakerRegion     string               `parquet:"bookmaker_region"`
+	Line                string               `parquet:"line"`
+	LineUnit            string               `parquet:"line_unit"`
+	LineValue           string               `parquet:"line_value"`
+	Odd                 *float64             `parquet:"odd,optional"`
+	Payout              float64              `parquet:"payout"`
+	OpeningOdd          *float64             `parquet:"opening_odd,optional"`
+	OpeningOddDate      string               `parquet:"opening_odd_date"`
+	OddsHistory         []ParquetOddsHistory `parquet:"odds_history,list"`
+	Season              string               `parquet:"season"`
+	Source              string               `parquet:"source"`
+}
+
+type ParquetOddsHistory struct {
+	Date   string  `parquet:"date"`
+	Odds   float64 `parquet:"odds"`
+	Change string  `parquet:"change"`
+}
+
+// PARQUET_COMPRESSION are the codecs accepted by -compression
+var PARQUET_COMPRESSION = map[string]compress.Codec{
+	"zstd":   &parquet.Zstd,
+	"snappy": &parquet.Snappy,
+	"gzip":   &parquet.Gzip,
+	"none":   &parquet.Uncompressed,
+}
+
+func processParquetFile(matches []Match) error {
+	codec, ok := PARQUET_COMPRESSION[compression]
+	if !ok {
+		return fmt.Errorf("unknown compression %q", compression)
+	}
+
+	fn := outputFileName(".parquet")
+	file, err := createAtomic(fn, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := parquet.NewGenericWriter[ParquetRow](file,
+		parquet.Compression(codec),
+		parquet.MaxRowsPerRowGroup(int64(rowGroupSize)),
+	)
+
+	countRows := 0
+	for _, match := range matches {
+		prepareMatch(&match)
+
+		var rows []ParquetRow
+		for marketType, marketData := range match.OddsData {
+			for _, lineData := range marketData {
+				bm := bookmakers.Lookup(lineData.Bookmaker)
+				for _, odd := range lineData.OddsData {
+					row := ParquetRow{
+						ID:                  int64(match.ID),
+						URL:                 match.URL,
+						HomeTeam:            match.HomeAbbreviation,
+						AwayTeam:            match.AwayAbbreviation,
+						HomeCanonical:       match.HomeCanonicalName,
+						AwayCanonical:       match.AwayCanonicalName,
+						HomeOriginal:        match.HomeName,
+						AwayOriginal:        match.AwayName,
+						Name:                match.Name,
+						EventStageName:      match.EventStageName,
+						TournamentStageName: match.TournamentStageName,
+						TournamentName:      match.TournamentName,
+						StartTime:           matchStart(&match).UTC(),
+						LocalDate:           match.DateLocal,
+						UTCDate:             match.DateUTC,
+						Result:              match.Result,
+						HomeResult:          match.HomeResult,
+						AwayResult:          match.AwayResult,
+						Partialresult:       match.Partialresult,
+						Market:              marketType,
+						Scope:               lineData.Scope,
+						Bookmaker:           lineData.Bookmaker,
+						BookmakerID:         lineData.BookmakerID,
+						BookmakerType:       bm.Type(),
+						BookmakerRegion:     bm.region(),
+						Line:                lineData.Line,
+						LineUnit:            lineData.LineUnit,
+						LineValue:           odd.LineValue,
+						Payout:              lineData.Payout,
+						OpeningOddDate:      odd.OpeningOdd.Date,
+						Season:              match.Season,
+						Source:              match.Source,
+					}
+					if !odd.Missing && odd.Odd > 0 {
+						row.Odd = &odd.Odd
+					}
+					if odd.OpeningOdd.Odds > 0 {
+						row.OpeningOdd = &odd.OpeningOdd.Odds
+					}
+					for _, h := range odd.OddsHistory {
+						row.OddsHistory = append(row.OddsHistory, ParquetOddsHistory(h))
+					}
+					rows = append(rows, row)
+				}
+			}
+		}
+
+		// Written per match, the writer flushes a row group every rowGroupSize rows
+		if _, err := writer.Write(rows); err != nil {
+			return fmt.Errorf("error writing rows: %w", err)
+		}
+		countRows += len(rows)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error closing Parquet writer: %w", err)
+	}
+	if err := file.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Compiled %v rows of data\n", countRows)
+	fmt.Println("Wrote Parquet File")
+	return nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// BrowserProfile is the identity a browser session presents to the site. The
+// user agent, client hints, viewport, locale and timezone are all derived
+// from it so they stay consistent with each other.
+type BrowserProfile struct {
+	Name          string `json:"name"`
+	UserAgent     string `json:"user_agent,omitempty"`     // Built from Platform and ChromeVersion if empty
+	ChromeVersion string `json:"chrome_version,omitempty"` // 130.0.6723.58 or just 130
+	Platform      string `json:"platform"`                 // Windows, macOS, Linux
+	Mobile        bool   `json:"mobile"`
+	Locale        string `json:"locale"`   // en-US
+	Timezone      string `json:"timezone"` // America/New_York
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+}
+
+var DEFAULT_PROFILES = []BrowserProfile{
+	{Name: "windows-chrome", ChromeVersion: "130", Platform: "Windows", Locale: "en-US", Timezone: "America/New_York", Width: 1920, Height: 1080},
+	{Name: "mac-chrome", ChromeVersion: "130", Platform: "macOS", Locale: "en-US", Timezone: "America/Chicago", Width: 1440, Height: 900},
+	{Name: "linux-chrome", ChromeVersion: "130", Platform: "Linux", Locale: "en-GB", Timezone: "Europe/London", Width: 1366, Height: 768},
+}
+
+var browserProfiles = DEFAULT_PROFILES
+var profileMu sync.Mutex
+var profileNext int
+
+// loadProfiles reads a JSON array of BrowserProfile from path.
+func loadProfiles(path string) ([]BrowserProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []BrowserProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("error unmarshaling profiles: %v", err)
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no profiles found in %s", path)
+	}
+
+	for i := range profiles {
+		bp := &profiles[i]
+		if bp.UserAgent == "" && bp.ChromeVersion == "" {
+			return nil, fmt.Errorf("profile %q needs either user_agent or chrome_version", bp.Name)
+		}
+		if bp.Platform == "" {
+			bp.Platform = "Windows"
+		}
+		if bp.Width == 0 || bp.Height == 0 {
+			bp.Width, bp.Height = 1920, 1080
+		}
+	}
+	return profiles, nil
+}
+
+// nextProfile returns a copy of the next profile, rotating once per browser session.
+func nextProfile() *BrowserProfile {
+	profileMu.Lock()
+	defer profileMu.Unlock()
+
+	bp := browserProfiles[profileNext%len(browserProfiles)]
+	profileNext++
+	return &bp
+}
+
+func (bp *BrowserProfile) majorVersion() string {
+	major, _, _ := strings.Cut(bp.ChromeVersion, ".")
+	return major
+}
+
+// fullVersion pads a major-only version the same way Chrome's reduced user agent does.
+func (bp *BrowserProfile) fullVersion() string {
+	if strings.Contains(bp.ChromeVersion, ".") {
+		return bp.ChromeVersion
+	}
+	return bp.ChromeVersion + ".0.0.0"
+}
+
+// navigatorPlatform is the navigator.platform value matching the profile.
+func (bp *BrowserProfile) navigatorPlatform() string {
+	switch bp.Platform {
+	case "macOS":
+		return "MacIntel"
+	case "Linux":
+		return "Linux x86_64"
+	}
+	return "Win32"
+}
+
+func (bp *BrowserProfile) UA() string {
+	if bp.UserAgent != "" {
+		return bp.UserAgent
+	}
+
+	var system string
+	switch bp.Platform {
+	case "macOS":
+		system = "Macintosh; Intel Mac OS X 10_15_7"
+	case "Linux":
+		system = "X11; Linux x86_64"
+	default:
+		system = "Windows NT 10.0; Win64; x64"
+	}
+	return fmt.Sprintf("Mozilla/5.0 (%s) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%s.0.0.0 Safari/537.36", system, bp.majorVersion())
+}
+
+func (bp *BrowserProfile) acceptLanguage() string {
+	if bp.Locale == "" {
+		return "en-US,en;q=0.5"
+	}
+	lang, _, _ := strings.Cut(bp.Locale, "-")
+	if lang == bp.Locale {
+		return bp.Locale
+	}
+	return fmt.Sprintf("%s,%s;q=0.9", bp.Locale, lang)
+}
+
+func (bp *BrowserProfile) brands(full bool) []*emulation.UserAgentBrandVersion {
+	v := bp.majorVersion()
+	if full {
+		v = bp.fullVersion()
+	}
+	return []*emulation.UserAgentBrandVersion{
+		{Brand: "Chromium", Version: v},
+		{Brand: "Google Chrome", Version: v},
+		{Brand: "Not?A_Brand", Version: "99"},
+	}
+}
+
+// Headers returns BASE_HEADERS plus the identity headers of the profile.
+func (bp *BrowserProfile) Headers() network.Headers {
+	h := network.Headers{}
+	for k, v := range BASE_HEADERS {
+		h[k] = v
+	}
+
+	var brands []string
+	for _, b := range bp.brands(false) {
+		brands = append(brands, fmt.Sprintf(`"%s";v="%s"`, b.Brand, b.Version))
+	}
+	mobile := "?0"
+	if bp.Mobile {
+		mobile = "?1"
+	}
+
+	h["User-Agent"] = bp.UA()
+	h["Accept-Language"] = bp.acceptLanguage()
+	h["Sec-Ch-Ua"] = strings.Join(brands, ", ")
+	h["Sec-Ch-Ua-Mobile"] = mobile
+	h["Sec-Ch-Ua-Platform"] = fmt.Sprintf(`"%s"`, bp.Platform)
+	return h
+}
+
+// profileAllocatorOptions are the exec allocator flags for the profile.
+func profileAllocatorOptions(bp *BrowserProfile) []chromedp.ExecAllocatorOption {
+	opts := []chromedp.ExecAllocatorOption{
+		chromedp.UserAgent(bp.UA()),
+		chromedp.WindowSize(bp.Width, bp.Height),
+	}
+	if bp.Locale != "" {
+		opts = append(opts, chromedp.Flag("lang", bp.Locale))
+	}
+	if bp.Timezone != "" {
+		opts = append(opts, chromedp.Env("TZ="+bp.Timezone))
+	}
+	return opts
+}
+
+// applyProfile overrides the user agent, client hints, locale and timezone of
+// the tab in ctx. With deriveHints the Chrome version is taken from the
+// running browser so the hints match what the browser actually is.
+func applyProfile(bp *BrowserProfile) chromedp.ActionFunc {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if deriveHints {
+			_, product, _, _, _, err := browser.GetVersion().Do(ctx)
+			if err != nil {
+				return fmt.Errorf("error getting browser version: %v", err)
+			}
+			// product is e.g. HeadlessChrome/130.0.6723.58
+			if _, v, ok := strings.Cut(product, "/"); ok {
+				bp.ChromeVersion = v
+				bp.UserAgent = ""
+			}
+			printDebug(fmt.Sprintf("Derived client hints from browser version %s", bp.ChromeVersion))
+		}
+
+		err := emulation.SetUserAgentOverride(bp.UA()).
+			WithAcceptLanguage(bp.acceptLanguage()).
+			WithPlatform(bp.navigatorPlatform()).
+			WithUserAgentMetadata(&emulation.UserAgentMetadata{
+				Brands:          bp.brands(false),
+				FullVersionList: bp.brands(true),
+				Platform:        bp.Platform,
+				Architecture:    "x86",
+				Bitness:         "64",
+				Mobile:          bp.Mobile,
+			}).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("error setting user agent: %v", err)
+		}
+
+		// Chrome flags don't reach a remote browser, so set the viewport here
+		if remoteURL != "" && bp.Width > 0 && bp.Height > 0 {
+			err := emulation.SetDeviceMetricsOverride(int64(bp.Width), int64(bp.Height), 1, bp.Mobile).Do(ctx)
+			if err != nil {
+				return fmt.Errorf("error setting viewport: %v", err)
+			}
+		}
+
+		if bp.Timezone != "" {
+			if err := emulation.SetTimezoneOverride(bp.Timezone).Do(ctx); err != nil {
+				return fmt.Errorf("error setting timezone: %v", err)
+			}
+		}
+		if bp.Locale != "" {
+			if err := emulation.SetLocaleOverride().WithLocale(strings.ReplaceAll(bp.Locale, "-", "_")).Do(ctx); err != nil {
+				return fmt.Errorf("error setting locale: %v", err)
+			}
+		}
+
+		printDebug(fmt.Sprintf("Using browser profile %s: %s", bp.Name, bp.UA()))
+		return network.SetExtraHTTPHeaders(bp.Headers()).Do(ctx)
+	})
+}
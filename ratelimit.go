@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// rateLimiter is a token bucket shared by every navigation, with a global
+// cooldown that pauses all callers once throttling has been detected.
+type rateLimiter struct {
+	mu            sync.Mutex
+	tokens        float64
+	capacity      float64
+	perSecond     float64
+	last          time.Time
+	cooldownUntil time.Time
+}
+
+var limiter = newRateLimiter(DEFAULT_RATE_PER_MINUTE, RATE_BURST)
+
+func newRateLimiter(perMinute float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		tokens:    float64(burst),
+		capacity:  float64(burst),
+		perSecond: perMinute / 60,
+		last:      time.Now(),
+	}
+}
+
+// setRate changes the refill rate, used after the flags have been parsed.
+func (l *rateLimiter) setRate(perMinute float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.perSecond = perMinute / 60
+}
+
+// Wait blocks until a token is available and no cooldown is active.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	for {
+		d := l.reserve()
+		if d <= 0 {
+			return nil
+		}
+
+		printDebug(fmt.Sprintf("Rate limiter waiting for %v", d.Round(time.Millisecond)))
+		if err := sleepContext(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// reserve takes a token if one is available, otherwise returns how long to wait.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.cooldownUntil) {
+		return l.cooldownUntil.Sub(now)
+	}
+
+	if l.perSecond <= 0 {
+		return 0
+	}
+
+	l.tokens = math.Min(l.capacity, l.tokens+now.Sub(l.last).Seconds()*l.perSecond)
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.perSecond * float64(time.Second))
+}
+
+// Cooldown pauses every caller of Wait for at least d.
+func (l *rateLimiter) Cooldown(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(l.cooldownUntil) {
+		l.cooldownUntil = until
+		printLog(fmt.Sprintf("Throttling detected, pausing all navigations for %v", d.Round(time.Second)))
+	}
+}
+
+// backoffDelay returns the exponential backoff with full jitter for the given
+// attempt, or the server's Retry-After if that is longer.
+func backoffDelay(attempt int, retryAfter time.Duration) time.Duration {
+	d := BACKOFF_BASE * time.Duration(1<<min(attempt, 10))
+	if d > BACKOFF_MAX {
+		d = BACKOFF_MAX
+	}
+	d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+
+	if retryAfter > d {
+		return retryAfter
+	}
+	return d
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(s); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func headerValue(h network.Headers, key string) string {
+	for k, v := range h {
+		if strings.EqualFold(k, key) {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func isThrottleStatus(status int64) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// throttleWatch records throttling responses seen by a browser tab, so the
+// navigation that triggered them can be retried outside of the event listener.
+type throttleWatch struct {
+	mu         sync.Mutex
+	status     int64
+	retryAfter time.Duration
+	throttled  int // 429s seen over the lifetime of the tab
+	blocked    int // 403s of documents seen over the lifetime of the tab
+}
+
+// listen attaches the watch to the tab in ctx. Document responses are checked
+// for 403/429/5xx, any other resource only for 429.
+func (w *throttleWatch) listen(ctx context.Context) {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		e, ok := ev.(*network.EventResponseReceived)
+		if !ok {
+			return
+		}
+		if e.Type == network.ResourceTypeDocument && e.Response.Status == http.StatusForbidden {
+			printLog(fmt.Sprintf("Received HTTP %d for %s", e.Response.Status, e.Response.URL))
+			w.mu.Lock()
+			w.blocked++
+			w.mu.Unlock()
+			return
+		}
+		if !isThrottleStatus(e.Response.Status) {
+			return
+		}
+		if e.Type != network.ResourceTypeDocument && e.Response.Status != http.StatusTooManyRequests {
+			return
+		}
+
+		ra := parseRetryAfter(headerValue(e.Response.Headers, "Retry-After"))
+		printLog(fmt.Sprintf("Received HTTP %d for %s", e.Response.Status, e.Response.URL))
+
+		w.mu.Lock()
+		w.status = e.Response.Status
+		if e.Response.Status == http.StatusTooManyRequests {
+			w.throttled++
+		}
+		if ra > w.retryAfter {
+			w.retryAfter = ra
+		}
+		w.mu.Unlock()
+
+		if e.Response.Status == http.StatusTooManyRequests {
+			limiter.Cooldown(backoffDelay(0, ra))
+		}
+	})
+}
+
+func (w *throttleWatch) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status = 0
+	w.retryAfter = 0
+}
+
+func (w *throttleWatch) get() (int64, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status, w.retryAfter
+}
+
+func (w *throttleWatch) Throttled() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.throttled
+}
+
+func (w *throttleWatch) Blocked() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.blocked
+}
+
+// navigate loads url through the shared limiter, retrying with exponential
+// backoff on errors and throttling responses up to MAX_NAV_ATTEMPTS times.
+func navigate(ctx context.Context, w *throttleWatch, url string) error {
+	var err error
+	for attempt := 0; attempt < MAX_NAV_ATTEMPTS; attempt++ {
+		if err = limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		w.reset()
+		err = chromedp.Run(ctx, chromedp.Navigate(url))
+		status, retryAfter := w.get()
+		if err == nil && !isThrottleStatus(status) {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("HTTP %d", status)
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
+		d := backoffDelay(attempt, retryAfter)
+		if status == http.StatusTooManyRequests {
+			limiter.Cooldown(d)
+		}
+		printLog(fmt.Sprintf("Navigation to %s failed (attempt %d/%d): %v. Retrying in %v", url, attempt+1, MAX_NAV_ATTEMPTS, err, d.Round(time.Second)))
+		if err := sleepContext(ctx, d); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("navigation to %s failed after %d attempts, last error: %w", url, MAX_NAV_ATTEMPTS, err)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// MatchFile is the layout of the page files and the combined JSON file.
+// Version 1 files are a bare array of matches with the site's mixed
+// kebab-case and camelCase keys.
+type MatchFile struct {
+	SchemaVersion int     `json:"schema_version"`
+	Matches       []Match `json:"matches"`
+}
+
+// ndjsonLine is one line of an NDJSON file, a match with its schema version.
+// Lines without schema_version are version 1.
+type ndjsonLine struct {
+	SchemaVersion int `json:"schema_version"`
+	*Match
+}
+
+// MIGRATIONS upgrade a decoded match of the version they are keyed by to the
+// next version. A change to the stored Match adds a migration and bumps
+// SCHEMA_VERSION.
+var MIGRATIONS = map[int]func(m map[string]any){
+	1: migrateV1,
+	2: migrateV2,
+	3: migrateV3,
+}
+
+// migrateV1 renames every key to snake_case, e.g. 'home-name' to 'home_name'
+// and 'maxOddsProviderId' to 'max_odds_provider_id'. The market keys of
+// odds_data are kept as they are.
+func migrateV1(m map[string]any) {
+	renamed := make(map[string]any, len(m))
+	for k, v := range m {
+		key := snakeCase(k)
+		renamed[key] = snakeKeys(v, key == "odds_data")
+	}
+	clear(m)
+	maps.Copy(m, renamed)
+}
+
+// migrateV2 leaves scraped_at, added by version 3, out. When the odds of an
+// older match were scraped is not recorded anywhere, and an empty scraped_at
+// is what the merge of combine expects: it sorts before every scrape time, so
+// a re-scraped record of the match wins over the old one.
+func migrateV2(m map[string]any) {}
+
+// migrateV3 leaves season and source, added by version 4, out. They are the
+// folder and path of the file a match was read from, which combine sets every
+// time it reads a file, a migrated match gets them on its next combine.
+func migrateV3(m map[string]any) {}
+
+func snakeKeys(v any, keepKeys bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, val := range v {
+			key := k
+			if !keepKeys {
+				key = snakeCase(k)
+			}
+			out[key] = snakeKeys(val, false)
+		}
+		return out
+	case []any:
+		for i := range v {
+			v[i] = snakeKeys(v[i], false)
+		}
+	}
+	return v
+}
+
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '-':
+			b.WriteByte('_')
+		case unicode.IsUpper(r):
+			if i > 0 && s[i-1] != '-' && s[i-1] != '_' {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// upgradeMatch decodes a match stored at the given version, migrating it to
+// SCHEMA_VERSION first when it is older.
+func upgradeMatch(data []byte, version int) (Match, error) {
+	var m Match
+	if version > SCHEMA_VERSION {
+		return m, fmt.Errorf("schema version %d is newer than %d, update the scraper", version, SCHEMA_VERSION)
+	}
+	if version == SCHEMA_VERSION {
+		err := json.Unmarshal(data, &m)
+		return m, err
+	}
+
+	// Numbers stay json.Number so ids and timestamps are not rounded
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return m, err
+	}
+	for v := version; v < SCHEMA_VERSION; v++ {
+		migrate, ok := MIGRATIONS[v]
+		if !ok {
+			return m, fmt.Errorf("no migration from schema version %d", v)
+		}
+		migrate(raw)
+	}
+	delete(raw, "schema_version")
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// decodeMatchFile decodes a JSON match file of any version, returning the
+// matches at SCHEMA_VERSION and the version the file was stored at.
+func decodeMatchFile(data []byte) ([]Match, int, error) {
+	var entries []json.RawMessage
+	version := 1
+
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, 0, fmt.Errorf("error unmarshalling JSON: %w", err)
+		}
+	} else {
+		var f struct {
+			SchemaVersion int               `json:"schema_version"`
+			Matches       []json.RawMessage `json:"matches"`
+		}
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, 0, fmt.Errorf("error unmarshalling JSON: %w", err)
+		}
+		if f.SchemaVersion == 0 {
+			return nil, 0, fmt.Errorf("no schema_version, not a match file")
+		}
+		entries, version = f.Matches, f.SchemaVersion
+	}
+
+	matches := make([]Match, 0, len(entries))
+	for i, e := range entries {
+		m, err := upgradeMatch(e, version)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error decoding match %d: %w", i+1, err)
+		}
+		matches = append(matches, m)
+	}
+	return matches, version, nil
+}
+
+func readMatchFile(path string) ([]Match, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	return decodeMatchFile(data)
+}
+
+// writeMatchFile writes the matches as a MatchFile at SCHEMA_VERSION.
+func writeMatchFile(path string, matches []Match, indent bool) error {
+	if matches == nil {
+		matches = []Match{}
+	}
+	f := MatchFile{SchemaVersion: SCHEMA_VERSION, Matches: matches}
+
+	var data []byte
+	var err error
+	if indent {
+		data, err = json.MarshalIndent(f, "", "  ")
+	} else {
+		data, err = json.Marshal(f)
+	}
+	if err != nil {
+		return fmt.Errorf("error marshalling JSON: %w", err)
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// newMatch builds a match from a row of the results page.
+func newMatch(r *RawMatch) Match {
+	m := Match{
+		ID:                      r.ID,
+		URL:                     r.URL,
+		IsDouble:                r.IsDouble,
+		Home:                    r.Home,
+		Away:                    r.Away,
+		HomeName:                r.HomeName,
+		AwayName:                r.AwayName,
+		HomeCountryTwoChartName: r.HomeCountryTwoChartName,
+		AwayCountryTwoChartName: r.AwayCountryTwoChartName,
+		HomeParticipantID:       r.HomeParticipantID,
+		AwayParticipantID:       r.AwayParticipantID,
+		StatusID:                r.StatusID,
+		EventStageID:            r.EventStageID,
+		EventStageName:          r.EventStageName,
+		TournamentStageID:       r.TournamentStageID,
+		TournamentStageTypeID:   r.TournamentStageTypeID,
+		TournamentStageGroupID:  r.TournamentStageGroupID,
+		TournamentStageName:     r.TournamentStageName,
+		SportID:                 r.SportID,
+		Cols:                    r.Cols,
+		CountryID:               r.CountryID,
+		CountryName:             r.CountryName,
+		CountryTwoChartName:     r.CountryTwoChartName,
+		CountryType:             r.CountryType,
+		TournamentID:            r.TournamentID,
+		TournamentName:          r.TournamentName,
+		TournamentURL:           r.TournamentURL,
+		HomeParticipantImages:   r.HomeParticipantImages,
+		AwayParticipantImages:   r.AwayParticipantImages,
+		SportURLName:            r.SportURLName,
+		Breadcrumbs:             r.Breadcrumbs,
+		EncodeEventID:           r.EncodeEventID,
+		ColClassName:            r.ColClassName,
+		HomeParticipantTypes:    r.HomeParticipantTypes,
+		AwayParticipantTypes:    r.AwayParticipantTypes,
+		DateStartBase:           r.DateStartBase,
+		DateStartTimestamp:      r.DateStartTimestamp,
+		Result:                  r.Result,
+		HomeResult:              r.HomeResult,
+		AwayResult:              r.AwayResult,
+		HomeWinner:              r.HomeWinner,
+		AwayWinner:              r.AwayWinner,
+		Partialresult:           r.Partialresult,
+		BookmakersCount:         r.BookmakersCount,
+		WinnerPost:              r.WinnerPost,
+		BettingType:             r.BettingType,
+		Name:                    r.Name,
+		ColClassNameTime:        r.ColClassNameTime,
+	}
+	for _, o := range r.Odds {
+		m.Odds = append(m.Odds, OutcomeOdds{
+			AvgOdds:           o.AvgOdds,
+			BettingTypeID:     o.BettingTypeID,
+			EventID:           o.EventID,
+			MaxOdds:           o.MaxOdds,
+			OutcomeResultID:   o.OutcomeResultID,
+			ScopeID:           o.ScopeID,
+			OutcomeID:         o.OutcomeID,
+			MaxOddsProviderID: o.MaxOddsProviderID,
+			Active:            o.Active,
+		})
+	}
+	setMatchDates(&m)
+	return m
+}
+
+// runMigrate upgrades the match files (.json and .ndjson) of -f, a file or
+// a folder, to SCHEMA_VERSION in place. Files already at the version and
+// files that are not match files are left alone.
+func runMigrate() {
+	path := filepath.FromSlash(filePath)
+	info, err := os.Stat(path)
+	if err != nil {
+		printLog(fmt.Sprintf("Error reading %s: %v", path, err))
+		return
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			printLog(fmt.Sprintf("Error reading directory: %v", err))
+			return
+		}
+		files = nil
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if e.Type().IsRegular() && (ext == ".json" || ext == ".ndjson") {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+
+	migrated := 0
+	for _, file := range files {
+		ok, err := migrateFile(file)
+		if err != nil {
+			printLog(fmt.Sprintf("Error migrating %s: %v", file, err))
+			continue
+		}
+		if ok {
+			migrated++
+		}
+	}
+	printLog(fmt.Sprintf("Migrated %d of %d files to schema version %d", migrated, len(files), SCHEMA_VERSION))
+}
+
+// migrateFile rewrites one file at SCHEMA_VERSION. Both writers replace the
+// file atomically, an interrupted migration leaves the old file intact.
+func migrateFile(file string) (bool, error) {
+	var matches []Match
+	oldest := SCHEMA_VERSION
+	err := readVersionedMatches(file, func(m *Match, version int) error {
+		matches = append(matches, *m)
+		oldest = min(oldest, version)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if oldest == SCHEMA_VERSION {
+		printLog(fmt.Sprintf("%s is already at schema version %d", file, SCHEMA_VERSION))
+		return false, nil
+	}
+
+	if strings.HasSuffix(file, ".ndjson") {
+		w, err := openNDJSON(file, true)
+		if err != nil {
+			return false, err
+		}
+		for i := range matches {
+			if err := w.Write(&matches[i]); err != nil {
+				w.atomic.Close()
+				return false, err
+			}
+		}
+		err = w.Close()
+	} else {
+		err = writeMatchFile(file, matches, true)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	printLog(fmt.Sprintf("Migrated %s from schema version %d to %d, %d matches", file, oldest, SCHEMA_VERSION, len(matches)))
+	return true, nil
+}
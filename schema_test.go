@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestUpgradeMatchV1(t *testing.T) {
+	data := []byte(`{
+		"id": 12345678901,
+		"home-name": "Boston Bruins",
+		"encodeEventId": "abc123",
+		"oddsData": {
+			"1X2": [{"bookmaker": "Pinnacle", "bookmakerId": "18", "oddsData": [{"lineValue": "1", "odd": 2.1}]}],
+			"OU-FT": [{"bookmaker": "Pinnacle", "line": "5.5", "oddsData": [{"lineValue": "1", "odd": 1.9}]}]
+		}
+	}`)
+
+	m, err := upgradeMatch(data, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 12345678901 || m.HomeName != "Boston Bruins" || m.EncodeEventID != "abc123" {
+		t.Errorf("match fields not migrated: %+v", m)
+	}
+	if len(m.OddsData) != 2 {
+		t.Fatalf("got markets %v, want 1X2 and OU-FT", m.OddsData)
+	}
+	rows := m.OddsData["1X2"]
+	if len(rows) != 1 || rows[0].BookmakerID != "18" || len(rows[0].OddsData) != 1 || rows[0].OddsData[0].LineValue != "1" || rows[0].OddsData[0].Odd != 2.1 {
+		t.Errorf("1X2 odds not migrated: %+v", rows)
+	}
+	if rows := m.OddsData["OU-FT"]; len(rows) != 1 || rows[0].Line != "5.5" {
+		t.Errorf("OU-FT odds not migrated: %+v", rows)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"home-name":          "home_name",
+		"encodeEventId":      "encode_event_id",
+		"maxOddsProviderId":  "max_odds_provider_id",
+		"home_name":          "home_name",
+		"date-start-base":    "date_start_base",
+		"homeParticipant-id": "home_participant_id",
+	}
+	for in, want := range tests {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+		if got := snakeCase(want); got != want {
+			t.Errorf("snakeCase(%q) = %q, not idempotent", want, got)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -16,9 +15,6 @@ import (
 )
 
 func scraper(url string, pagecount int) {
-	ctx, cancel := chromedp.NewContext(context.Background())
-	defer cancel()
-
 	var filename string
 	var url_ string
 	if pagecount != 0 {
@@ -35,15 +31,21 @@ func scraper(url string, pagecount int) {
 		return
 	}
 
+	p := proxies.Next()
+	ctx, err := session.Acquire(p)
+	if err != nil {
+		proxies.Report(p, false)
+		fmt.Println(err)
+		return
+	}
+
 	chromedp.ListenTarget(
 		ctx,
 		func(ev interface{}) {
 			if ev, ok := ev.(*network.EventResponseReceived); ok {
 
-				// Check for HTTP 429 status, e.g too many requests
-				if ev.Response.Status == 429 {
-					fmt.Println("Received HTTP 429 - Too Many Requests. Waiting for 15 seconds...")
-					time.Sleep(15 + time.Duration(rand.Intn(15)))
+				// Throttled responses are handled by the throttle watch
+				if isThrottleStatus(ev.Response.Status) {
 					return
 				}
 
@@ -83,13 +85,17 @@ func scraper(url string, pagecount int) {
 					TotalPages = int(math.Ceil(float64(pageData.D.Total) / float64(pageData.D.OnePage)))
 					fmt.Printf("Scraping Page %v out of %v..\n", pageData.D.Page, TotalPages)
 
-					rowsJSON, err := json.Marshal(pageData.D.Rows)
-					if err != nil {
-						fmt.Println("Error marshaling rows data:", err)
-						return
+					matches := make([]Match, 0, len(pageData.D.Rows))
+					for _, row := range pageData.D.Rows {
+						var raw RawMatch
+						if err := json.Unmarshal(row, &raw); err != nil {
+							fmt.Println("Error unmarshaling row:", err)
+							return
+						}
+						matches = append(matches, newMatch(&raw))
 					}
 
-					err = os.WriteFile(filename, rowsJSON, 0644)
+					err = writeMatchFile(filename, matches, false)
 					if err != nil {
 						fmt.Println("Error writing file:", err)
 						return
@@ -102,12 +108,15 @@ func scraper(url string, pagecount int) {
 		},
 	)
 
-	err := chromedp.Run(ctx,
-		network.Enable(),
-		network.SetExtraHTTPHeaders(HEADERS),
-		chromedp.Navigate(url_),
-		chromedp.Sleep(time.Second*time.Duration((10+rand.Intn(15)))),
-	)
+	var w throttleWatch
+	w.listen(ctx)
+
+	err = navigate(ctx, &w, url_)
+	if err == nil {
+		err = chromedp.Run(ctx, chromedp.Sleep(time.Second*time.Duration((10+rand.Intn(15)))))
+	}
+	session.Release(err)
+	proxies.Report(p, !proxyFailed(err, &w))
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/inspector"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+)
+
+// Session keeps one long-lived browser with a single reused tab for the whole
+// run. The browser is recycled after recycleAfter pages, when it crashes or
+// when a different proxy is requested. Cookies and local storage are saved to
+// sessionFile so the next browser (or the next run) skips the cookie consent
+// and starts with a warm session.
+type Session struct {
+	mu sync.Mutex
+
+	cancelAlloc   context.CancelFunc
+	cancelBrowser context.CancelFunc
+	tabCtx        context.Context
+	cancelTab     context.CancelFunc
+	cancelUse     context.CancelFunc
+
+	proxy   *Proxy
+	profile *BrowserProfile
+	pages   int
+	crashed atomic.Bool
+}
+
+// SessionState is the browser state persisted between runs.
+type SessionState struct {
+	Cookies      []*network.Cookie `json:"cookies"`
+	LocalStorage map[string]string `json:"local_storage"`
+}
+
+var session = &Session{}
+
+// Acquire returns a context for the shared tab, starting or recycling the
+// browser as needed. The session is held until Release is called, listeners
+// registered on the returned context are removed on Release.
+func (s *Session) Acquire(p *Proxy) (context.Context, error) {
+	s.mu.Lock()
+
+	if s.tabCtx != nil && (s.crashed.Load() || s.tabCtx.Err() != nil || s.proxy != p) {
+		s.recycle()
+	}
+
+	if s.tabCtx == nil {
+		if err := s.start(p); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(s.tabCtx)
+	s.cancelUse = cancel
+	return ctx, nil
+}
+
+// Release hands the tab back after a page has been scraped, err is the
+// outcome of the scrape and is used to detect a crashed browser.
+func (s *Session) Release(err error) {
+	defer s.mu.Unlock()
+
+	if s.cancelUse != nil {
+		s.cancelUse()
+		s.cancelUse = nil
+	}
+
+	s.pages++
+	if s.tabCtx.Err() != nil {
+		s.crashed.Store(true)
+	}
+	if err == nil && !s.crashed.Load() {
+		s.saveState()
+	}
+
+	if s.crashed.Load() || (recycleAfter > 0 && s.pages >= recycleAfter) {
+		s.recycle()
+	}
+}
+
+// Close saves the session state and shuts the browser down.
+func (s *Session) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tabCtx == nil {
+		return
+	}
+	if !s.crashed.Load() {
+		s.saveState()
+	}
+	s.stop()
+}
+
+// allocatorOptions returns the exec allocator options for a browser presenting
+// profile bp and routed through p, or a direct connection if p is nil.
+func allocatorOptions(p *Proxy, bp *BrowserProfile) []chromedp.ExecAllocatorOption {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", !isDebug),
+		chromedp.Flag("disable-gpu", !isDebug),
+	)
+	opts = append(opts, profileAllocatorOptions(bp)...)
+	if p != nil {
+		opts = append(opts, chromedp.ProxyServer(p.Server()))
+	}
+	return opts
+}
+
+func (s *Session) start(p *Proxy) error {
+	bp := nextProfile()
+
+	var allocCtx context.Context
+	var cancelAlloc context.CancelFunc
+	if remoteURL != "" {
+		allocCtx, cancelAlloc = chromedp.NewRemoteAllocator(context.Background(), remoteURL)
+	} else {
+		allocCtx, cancelAlloc = chromedp.NewExecAllocator(context.Background(), allocatorOptions(p, bp)...)
+	}
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	tabCtx, cancelTab := browserCtx, cancelBrowser
+
+	if remoteURL != "" {
+		// The remote browser is shared, so our tab gets its own browser
+		// context which keeps the cookies separate and carries the proxy.
+		if err := chromedp.Run(browserCtx); err != nil {
+			cancelBrowser()
+			cancelAlloc()
+			return fmt.Errorf("error connecting to remote browser %s: %v", remoteURL, err)
+		}
+		tabCtx, cancelTab = chromedp.NewContext(browserCtx, chromedp.WithNewBrowserContext(
+			func(params *target.CreateBrowserContextParams) *target.CreateBrowserContextParams {
+				if p != nil {
+					return params.WithProxyServer(p.Server())
+				}
+				return params
+			},
+		))
+	}
+
+	s.crashed.Store(false)
+	chromedp.ListenTarget(tabCtx, func(ev interface{}) {
+		if _, ok := ev.(*inspector.EventTargetCrashed); ok {
+			printLog("Browser tab crashed, recycling browser")
+			s.crashed.Store(true)
+		}
+	})
+
+	err := chromedp.Run(tabCtx,
+		proxyAuth(p),
+		network.Enable(),
+		applyProfile(bp),
+		s.restoreState(),
+	)
+	if err != nil {
+		cancelTab()
+		cancelBrowser()
+		cancelAlloc()
+		return fmt.Errorf("error starting browser: %v", err)
+	}
+
+	s.cancelAlloc = cancelAlloc
+	s.cancelBrowser = cancelBrowser
+	s.tabCtx = tabCtx
+	s.cancelTab = cancelTab
+	s.proxy = p
+	s.profile = bp
+	s.pages = 0
+
+	if remoteURL != "" {
+		printLog(fmt.Sprintf("Connected to remote browser %s (proxy: %s, profile: %s)", remoteURL, p, bp.Name))
+	} else {
+		printLog(fmt.Sprintf("Started browser (proxy: %s, profile: %s)", p, bp.Name))
+	}
+	return nil
+}
+
+// stop closes the tab and the browser. A remote browser is only disconnected
+// from, it keeps running for its other users.
+func (s *Session) stop() {
+	s.cancelTab()
+	s.cancelBrowser()
+	s.cancelAlloc()
+	s.tabCtx = nil
+}
+
+func (s *Session) recycle() {
+	printDebug(fmt.Sprintf("Recycling browser after %d pages", s.pages))
+	if !s.crashed.Load() && s.tabCtx.Err() == nil {
+		s.saveState()
+	}
+	s.stop()
+}
+
+// saveState writes the cookies and the local storage of BASEURL to sessionFile.
+func (s *Session) saveState() {
+	if sessionFile == "" {
+		return
+	}
+
+	var state SessionState
+	var ls string
+	err := chromedp.Run(s.tabCtx,
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			state.Cookies, err = storage.GetCookies().Do(ctx)
+			return err
+		}),
+		chromedp.Evaluate(fmt.Sprintf(`location.origin === %q ? JSON.stringify(Object.assign({}, localStorage)) : ""`, BASEURL), &ls),
+	)
+	if err != nil {
+		printLog(fmt.Sprintf("Error reading session state: %v", err))
+		return
+	}
+
+	if ls != "" {
+		if err := json.Unmarshal([]byte(ls), &state.LocalStorage); err != nil {
+			printDebug(fmt.Sprintf("Error unmarshaling local storage: %v", err))
+		}
+	} else if old, err := readSessionState(); err == nil {
+		// Not on the site right now, keep what we had
+		state.LocalStorage = old.LocalStorage
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		printLog(fmt.Sprintf("Error marshaling session state: %v", err))
+		return
+	}
+	if err := writeFileAtomic(sessionFile, data, 0600); err != nil {
+		printLog(fmt.Sprintf("Error writing session state: %v", err))
+	}
+}
+
+func readSessionState() (SessionState, error) {
+	var state SessionState
+	data, err := os.ReadFile(sessionFile)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// restoreState loads the cookies from sessionFile into the browser and seeds
+// the local storage of BASEURL before any of its scripts run.
+func (s *Session) restoreState() chromedp.ActionFunc {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if sessionFile == "" {
+			return nil
+		}
+
+		state, err := readSessionState()
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			printLog(fmt.Sprintf("Error reading session file %s, starting fresh: %v", sessionFile, err))
+			return nil
+		}
+
+		var params []*network.CookieParam
+		for _, c := range state.Cookies {
+			cp := &network.CookieParam{
+				Name:         c.Name,
+				Value:        c.Value,
+				Domain:       c.Domain,
+				Path:         c.Path,
+				Secure:       c.Secure,
+				HTTPOnly:     c.HTTPOnly,
+				SameSite:     c.SameSite,
+				Priority:     c.Priority,
+				SourceScheme: c.SourceScheme,
+				SourcePort:   c.SourcePort,
+				PartitionKey: c.PartitionKey,
+			}
+			if !c.Session {
+				t := cdp.TimeSinceEpoch(time.Unix(0, int64(c.Expires*float64(time.Second))))
+				cp.Expires = &t
+			}
+			params = append(params, cp)
+		}
+		if len(params) > 0 {
+			if err := storage.SetCookies(params).Do(ctx); err != nil {
+				return fmt.Errorf("error restoring cookies: %v", err)
+			}
+		}
+
+		if len(state.LocalStorage) > 0 {
+			items, err := json.Marshal(state.LocalStorage)
+			if err != nil {
+				return err
+			}
+			_, err = page.AddScriptToEvaluateOnNewDocument(fmt.Sprintf(`
+				if (location.origin === %q) {
+					for (const [k, v] of Object.entries(%s)) {
+						if (localStorage.getItem(k) === null) localStorage.setItem(k, v);
+					}
+				}
+			`, BASEURL, items)).Do(ctx)
+			if err != nil {
+				return fmt.Errorf("error restoring local storage: %v", err)
+			}
+		}
+
+		printDebug(fmt.Sprintf("Restored %d cookies and %d local storage items", len(params), len(state.LocalStorage)))
+		return nil
+	})
+}
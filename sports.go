@@ -0,0 +1,163 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SportProfile describes the markets OddsPortal lists for a sport, their
+// outcome labels and what the lines of the line markets are counted in.
+type SportProfile struct {
+	Name     string              // URL name, e.g. 'hockey'
+	ID       int                 // OddsPortal sport id
+	LineUnit string              // goals, points, runs, games, sets, maps
+	Markets  map[string][]string // market suffix -> outcome labels, nil for the MARKET_OUTCOMES defaults
+	Units    map[string]string   // market suffix -> line unit, if it differs from LineUnit
+}
+
+var SPORT_PROFILES = []SportProfile{
+	{
+		Name:     "soccer",
+		ID:       1,
+		LineUnit: "goals",
+		Markets:  markets("1X2", "home-away", "over-under", "ah", "eh", "cs", "bts", "double", "dnb", "odd-even", "ht-ft"),
+	},
+	{
+		Name:     "tennis",
+		ID:       2,
+		LineUnit: "games",
+		Markets:  markets("home-away", "over-under", "ah", "cs", "odd-even"),
+		Units:    map[string]string{"cs": "sets"},
+	},
+	{
+		Name:     "basketball",
+		ID:       3,
+		LineUnit: "points",
+		Markets:  markets("home-away", "1X2", "over-under", "ah", "eh", "double", "dnb", "odd-even", "ht-ft"),
+	},
+	{
+		Name:     "hockey",
+		ID:       4,
+		LineUnit: "goals",
+		Markets:  markets("1X2", "home-away", "over-under", "ah", "eh", "cs", "bts", "double", "dnb", "odd-even"),
+	},
+	{
+		Name:     "american-football",
+		ID:       5,
+		LineUnit: "points",
+		Markets:  markets("home-away", "1X2", "over-under", "ah", "eh", "double", "dnb", "odd-even", "ht-ft"),
+	},
+	{
+		// The asian handicap of baseball is the run line
+		Name:     "baseball",
+		ID:       6,
+		LineUnit: "runs",
+		Markets:  markets("home-away", "1X2", "over-under", "ah", "eh", "double", "dnb", "odd-even"),
+	},
+	{
+		Name:     "handball",
+		ID:       7,
+		LineUnit: "goals",
+		Markets:  markets("1X2", "home-away", "over-under", "ah", "eh", "double", "dnb", "odd-even", "ht-ft"),
+	},
+	{
+		Name:     "volleyball",
+		ID:       12,
+		LineUnit: "points",
+		Markets:  markets("home-away", "over-under", "ah", "cs", "odd-even"),
+		Units:    map[string]string{"cs": "sets"},
+	},
+	{
+		Name:     "esports",
+		ID:       36,
+		LineUnit: "maps",
+		Markets:  markets("home-away", "1X2", "over-under", "ah", "cs"),
+	},
+}
+
+// GENERIC_SPORT is used for sports without a profile, it accepts any market.
+var GENERIC_SPORT = SportProfile{Name: "generic"}
+
+// markets lists the markets of a sport with the default outcome labels.
+func markets(names ...string) map[string][]string {
+	m := make(map[string][]string, len(names))
+	for _, n := range names {
+		m[n] = nil
+	}
+	return m
+}
+
+// sportProfile picks the profile by the sport's URL name, then by its id.
+func sportProfile(id int, name string) *SportProfile {
+	name = strings.ToLower(name)
+	for i := range SPORT_PROFILES {
+		if name != "" && SPORT_PROFILES[i].Name == name {
+			return &SPORT_PROFILES[i]
+		}
+	}
+	for i := range SPORT_PROFILES {
+		if id != 0 && SPORT_PROFILES[i].ID == id {
+			return &SPORT_PROFILES[i]
+		}
+	}
+	return &GENERIC_SPORT
+}
+
+// sportFromURL picks the profile from the first path element of a match URL,
+// e.g. 'https://www.oddsportal.com/hockey/usa/nhl/...'.
+func sportFromURL(u string) *SportProfile {
+	path := strings.TrimPrefix(strings.TrimPrefix(u, BASEURL), "/")
+	name, _, _ := strings.Cut(path, "/")
+	return sportProfile(0, name)
+}
+
+// matchSport returns the profile for a match from the base data.
+func matchSport(m *Match) *SportProfile {
+	sp := sportProfile(m.SportID, m.SportURLName)
+	if sp == &GENERIC_SPORT {
+		return sportFromURL(m.URL)
+	}
+	return sp
+}
+
+// HasMarket reports whether the market is listed for the sport, the generic
+// profile has every market.
+func (sp *SportProfile) HasMarket(market string) bool {
+	if sp.Markets == nil {
+		return true
+	}
+	_, ok := sp.Markets[market]
+	return ok
+}
+
+// outcomeLabels returns the outcome labels for a row with n value cells
+// (line and odds): the sport's or the default labels of the market, so the
+// stored labels don't change with the page, otherwise the header of the
+// table, otherwise the outcomes are just numbered.
+func (sp *SportProfile) outcomeLabels(market string, header []string, n int) []string {
+	labels := sp.Markets[market]
+	if labels == nil {
+		labels = MARKET_OUTCOMES[market]
+	}
+	if labels != nil && len(labels) <= n {
+		return labels
+	}
+	if len(header) > 0 && len(header) <= n {
+		return header
+	}
+
+	labels = make([]string, n)
+	for i := range labels {
+		labels[i] = strconv.Itoa(i + 1)
+	}
+	return labels
+}
+
+// lineUnit is what the line of the market is counted in, e.g. runs for the
+// run line of baseball or sets for the correct score of tennis.
+func (sp *SportProfile) lineUnit(market string) string {
+	if u, ok := sp.Units[market]; ok {
+		return u
+	}
+	return sp.LineUnit
+}
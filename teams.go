@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// TeamAlias maps the names and participant ids a team has on OddsPortal to
+// one canonical name and abbreviation. From/Until limit the alias to a date
+// range, so a relocated or renamed team (e.g. Arizona -> Utah) keeps the
+// right name for the seasons before and after the change.
+type TeamAlias struct {
+	Sport          string   `json:"sport,omitempty"`  // hockey, empty for any sport
+	League         string   `json:"league,omitempty"` // NHL, informational
+	Canonical      string   `json:"canonical"`
+	Abbreviation   string   `json:"abbreviation"`
+	Names          []string `json:"names,omitempty"`
+	ParticipantIDs []int    `json:"participant_ids,omitempty"`
+	From           string   `json:"from,omitempty"`  // 2024-07-01, inclusive
+	Until          string   `json:"until,omitempty"` // 2024-06-30, inclusive
+}
+
+// TeamRegistry resolves teams by participant id first and by name second.
+type TeamRegistry struct {
+	aliases []TeamAlias
+}
+
+func nhl(canonical string, abbreviation string, names ...string) TeamAlias {
+	return TeamAlias{
+		Sport:        "hockey",
+		League:       "NHL",
+		Canonical:    canonical,
+		Abbreviation: abbreviation,
+		Names:        append([]string{canonical}, names...),
+	}
+}
+
+var DEFAULT_TEAMS = []TeamAlias{
+	nhl("Anaheim Ducks", "ANA"),
+	nhl("Boston Bruins", "BOS"),
+	nhl("Buffalo Sabres", "BUF"),
+	nhl("Calgary Flames", "CGY"),
+	nhl("Carolina Hurricanes", "CAR"),
+	nhl("Chicago Blackhawks", "CHI"),
+	nhl("Colorado Avalanche", "COL"),
+	nhl("Columbus Blue Jackets", "CBJ"),
+	nhl("Dallas Stars", "DAL"),
+	nhl("Detroit Red Wings", "DET"),
+	nhl("Edmonton Oilers", "EDM"),
+	nhl("Florida Panthers", "FLA"),
+	nhl("Los Angeles Kings", "LAK"),
+	nhl("Minnesota Wild", "MIN"),
+	nhl("Montreal Canadiens", "MTL"),
+	nhl("Nashville Predators", "NSH"),
+	nhl("New Jersey Devils", "NJD"),
+	nhl("New York Islanders", "NYI"),
+	nhl("New York Rangers", "NYR"),
+	nhl("Ottawa Senators", "OTT"),
+	nhl("Philadelphia Flyers", "PHI"),
+	nhl("Pittsburgh Penguins", "PIT"),
+	nhl("San Jose Sharks", "SJS"),
+	nhl("Seattle Kraken", "SEA"),
+	nhl("St. Louis Blues", "STL"),
+	nhl("Tampa Bay Lightning", "TBL"),
+	nhl("Toronto Maple Leafs", "TOR"),
+	nhl("Vancouver Canucks", "VAN"),
+	nhl("Vegas Golden Knights", "VGK"),
+	nhl("Washington Capitals", "WSH"),
+	nhl("Winnipeg Jets", "WPG"),
+	{Sport: "hockey", League: "NHL", Canonical: "Arizona Coyotes", Abbreviation: "AZN", Names: []string{"Arizona Coyotes", "Arizona"}, Until: "2024-06-30"},
+	{Sport: "hockey", League: "NHL", Canonical: "Utah Hockey Club", Abbreviation: "UTA", Names: []string{"Utah", "Utah Hockey Club", "Utah Mammoth"}, From: "2024-07-01"},
+}
+
+var teams = &TeamRegistry{aliases: DEFAULT_TEAMS}
+
+// loadTeamAliases reads a JSON array of TeamAlias from path. The aliases from
+// the file are checked before the built-in ones.
+func loadTeamAliases(path string) (*TeamRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var aliases []TeamAlias
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("error unmarshaling team aliases: %v", err)
+	}
+
+	for _, a := range aliases {
+		if a.Canonical == "" {
+			return nil, fmt.Errorf("team alias %v has no canonical name", a.Names)
+		}
+		for _, d := range []string{a.From, a.Until} {
+			if _, err := parseAliasDate(d); err != nil {
+				return nil, fmt.Errorf("team alias %s: %v", a.Canonical, err)
+			}
+		}
+	}
+
+	return &TeamRegistry{aliases: append(aliases, DEFAULT_TEAMS...)}, nil
+}
+
+func parseAliasDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// activeAt reports whether the alias is valid on the match date.
+func (a *TeamAlias) activeAt(date time.Time) bool {
+	if from, _ := parseAliasDate(a.From); !from.IsZero() && date.Before(from) {
+		return false
+	}
+	if until, _ := parseAliasDate(a.Until); !until.IsZero() && !date.Before(until.AddDate(0, 0, 1)) {
+		return false
+	}
+	return true
+}
+
+// Lookup finds the alias for a participant of a sport on the given date.
+func (tr *TeamRegistry) Lookup(sport string, participantID int, name string, date time.Time) *TeamAlias {
+	candidates := make([]*TeamAlias, 0, 2)
+	for i := range tr.aliases {
+		a := &tr.aliases[i]
+		if a.Sport != "" && sport != "" && a.Sport != sport {
+			continue
+		}
+		candidates = append(candidates, a)
+	}
+
+	if participantID != 0 {
+		for _, a := range candidates {
+			for _, id := range a.ParticipantIDs {
+				if id == participantID && a.activeAt(date) {
+					return a
+				}
+			}
+		}
+	}
+
+	name = strings.TrimSpace(name)
+	for _, a := range candidates {
+		for _, n := range a.Names {
+			if strings.EqualFold(n, name) && a.activeAt(date) {
+				return a
+			}
+		}
+	}
+	return nil
+}
+
+// resolveTeams fills the canonical names and abbreviations of both teams.
+// The original names in HomeName/AwayName are left untouched, teams not in
+// the registry keep their original name in both fields.
+func resolveTeams(m *Match) {
+	date := matchStart(m)
+
+	m.HomeCanonicalName, m.HomeAbbreviation = m.HomeName, m.HomeName
+	if a := teams.Lookup(m.SportURLName, m.HomeParticipantID, m.HomeName, date); a != nil {
+		m.HomeCanonicalName, m.HomeAbbreviation = a.Canonical, a.Abbreviation
+	}
+
+	m.AwayCanonicalName, m.AwayAbbreviation = m.AwayName, m.AwayName
+	if a := teams.Lookup(m.SportURLName, m.AwayParticipantID, m.AwayName, date); a != nil {
+		m.AwayCanonicalName, m.AwayAbbreviation = a.Canonical, a.Abbreviation
+	}
+}
@@ -4,18 +4,17 @@ import (
 	"fmt"
 	"math/rand"
 	"slices"
-	"strconv"
 	"strings"
 	"time"
 )
 
 func printLog(s string) {
-	fmt.Printf("%s - LOG:\t%s\n", time.Now().Format("2024/01/01 13:45:00"), s)
+	fmt.Printf("%s - LOG:\t%s\n", time.Now().Format(time.RFC3339), s)
 }
 
 func printDebug(s string) {
 	if isDebug {
-		fmt.Printf("%s - DEBUG:\t%s\n", time.Now().Format("2024/01/01 13:45:00"), s)
+		fmt.Printf("%s - DEBUG:\t%s\n", time.Now().Format(time.RFC3339), s)
 	}
 }
 
@@ -58,8 +57,9 @@ func calculatePayout(odds []OddsData) float64 {
 
 	product := 1.0
 	for _, odd := range odds {
-		if odd.Odd == 0 {
-			continue
+		if odd.Missing || odd.Odd == 0 {
+			// No payout without the odds of every outcome
+			return 0
 		}
 
 		product *= odd.Odd
@@ -67,113 +67,52 @@ func calculatePayout(odds []OddsData) float64 {
 	return 1 - (1 / product)
 }
 
-func parseLineValue(name string) string {
-	switch name {
-	case "#1X2;2":
-		return "1X2"
-	case "#home-away;1":
-		return "ML"
-	case "#over-under;1":
-		return "OU-ML"
-	case "#over-under;2":
-		return "OU-FT"
-	case "#ah;1":
-		return "AH-ML"
-	case "#ah;2":
-		return "AH-FT"
-	case "#bts;2":
-		return "BTTS"
-	case "#double;2":
-		return "DC"
-	case "#eh;2":
-		return "EH"
-	case "#dnb;2":
-		return "DNB"
-	}
-	return ""
+// splitSuffix splits a URL suffix like '#over-under;2' into the market and the scope id.
+func splitSuffix(s string) (string, string) {
+	market, scopeID, _ := strings.Cut(strings.TrimPrefix(s, "#"), ";")
+	return market, scopeID
 }
 
-func getLineValue(line string, i int) string {
-	switch line {
-	case "1X2":
-		switch i {
-		case 1:
-			return "1"
-		case 2:
-			return "X"
-		case 3:
-			return "2"
-		}
-	case "ML":
-		switch i {
-		case 1:
-			return "1"
-		case 2:
-			return "2"
-		}
-	case "ML-OU":
-		switch i {
-		case 2:
-			return "Over"
-		case 3:
-			return "Under"
-		}
-	case "FT-OU":
-		switch i {
-		case 2:
-			return "Over"
-		case 3:
-			return "Under"
-		}
-	case "AH-ML":
-		switch i {
-		case 2:
-			return "1"
-		case 3:
-			return "2"
-		}
-	case "AH-FT":
-		switch i {
-		case 2:
-			return "1"
-		case 3:
-			return "2"
-		}
-	case "DNB":
-		switch i {
-		case 1:
-			return "1"
-		case 2:
-			return "2"
-		}
-	case "BTTS":
-		switch i {
-		case 1:
-			return "Yes"
-		case 2:
-			return "No"
-		}
-	case "DC":
-		switch i {
-		case 1:
-			return "1X"
-		case 2:
-			return "12"
-		case 3:
-			return "X2"
-		}
-	case "EH":
-		switch i {
-		case 1:
-			return "1"
-		case 2:
-			return "X"
-		case 3:
-			return "2"
+// parseScope returns the scope code for a scope tab label, falling back to the
+// scope id in the suffix when the page has no scope tabs.
+func parseScope(label string, suffix string) string {
+	if label != "" {
+		if code, ok := SCOPE_CODES[label]; ok {
+			return code
 		}
+		return strings.ToUpper(strings.Join(strings.Fields(label), "-"))
+	}
+
+	_, scopeID := splitSuffix(suffix)
+	if code, ok := SCOPE_IDS[scopeID]; ok {
+		return code
+	}
+	return scopeID
+}
+
+// parseLineValue returns the OddsData key for a market suffix and scope code,
+// e.g. 'OU-FT' or '1X2-P1'. The full time keys of the single scope markets
+// (1X2, BTTS, DC, EH, DNB, CS) and the moneyline (ML) keep their historical names.
+func parseLineValue(s string, scope string) string {
+	market, _ := splitSuffix(s)
+	code, ok := MARKET_CODES[market]
+	if !ok {
+		// Markets we don't know yet get their suffix as code, e.g. 'TEAM-TOTALS'
+		code = strings.ToUpper(market)
+	}
+	if scope == "" {
+		scope = parseScope("", s)
 	}
 
-	return ""
+	switch {
+	case scope == "FT" && slices.Contains([]string{"1X2", "BTTS", "DC", "EH", "DNB", "CS"}, code):
+		return code
+	case scope == "ML" && code == "HA":
+		return "ML"
+	case scope == "":
+		return code
+	}
+	return code + "-" + scope
 }
 
 func parseURLSuffix(url string) string {
@@ -181,160 +120,110 @@ func parseURLSuffix(url string) string {
 	return parts[len(parts)-1]
 }
 
-func isWantedBookmaker(s string) bool {
-	return slices.Contains(BOOKMAKERS_TO_SCRAPE, strings.ToLower(s))
-}
+// parseOddsTable turns the raw table into odds rows. The last cells of a row
+// are the odds, one per outcome label, anything before them is the line
+// (handicap, total or score) and a trailing percentage is the payout. An
+// empty odds cell is stored as missing.
+func parseOddsTable(t *OddsTable, s string, scope string, sp *SportProfile) []OddRow {
+	market, _ := splitSuffix(s)
 
-func isTwoColumn(s string) bool {
-	return slices.Contains(TWO_COL_CELLS, strings.ToLower(s))
-}
-
-func isThreeColumn(s string) bool {
-	return slices.Contains(THREE_COL_CELLS, strings.ToLower(s))
-}
-
-func parseFloat(s string) float64 {
-	f, err := strconv.ParseFloat(s, 64)
-	if err != nil {
-		return 0
-	}
-	return f
-}
+	var rows []OddRow
+	for _, r := range t.Rows {
+		if !bookmakerFilter.Wants(r.Bookmaker) {
+			continue
+		}
 
-func getCellValue(r *RawOddRow, cell int) float64 {
-	switch cell {
-	case 1:
-		return parseFloat(r.FirstCell)
-	case 2:
-		return parseFloat(r.SecondCell)
-	case 3:
-		return parseFloat(r.ThirdCell)
-	}
+		// Empty cells are kept in place, they are locked or missing outcomes
+		cells := slices.DeleteFunc(slices.Clone(r.Cells), func(c string) bool {
+			return strings.HasSuffix(c, "%")
+		})
+		if !slices.ContainsFunc(cells, func(c string) bool { return c != "" }) {
+			continue
+		}
 
-	return 0
-}
+		labels := sp.outcomeLabels(market, t.Header, len(cells))
+		lineCells := cells[:len(cells)-len(labels)]
+		oddCells := cells[len(cells)-len(labels):]
 
-func parseRowData(r *RawOddRow, s string) OddRow {
-	o := OddRow{}
+		o := OddRow{
+			Bookmaker:   r.Bookmaker,
+			BookmakerID: bookmakers.CanonicalID(r.Bookmaker),
+			Scope:       scope,
+			Line:        parseLineValue(s, scope),
+		}
+		if len(lineCells) > 0 {
+			o.Line = strings.Join(lineCells, " ")
+			o.LineUnit = sp.lineUnit(market)
+			if market == "cs" {
+				o.Line = normalizeScore(o.Line)
+			}
+		}
 
-	o.Bookmaker = r.Bookmaker
-	o.Line = parseLineValue(s)
-	if s == "#home-away;1" || s == "#home-away;2" || s == "#bts;2" || s == "#dnb;2" {
-		o.OddsData = append(o.OddsData, getOddsData(parseLineValue(s), r, 1))
-		o.OddsData = append(o.OddsData, getOddsData(parseLineValue(s), r, 2))
-	} else if isTwoColumn(s) {
-		o.Line = r.FirstCell
-		o.OddsData = append(o.OddsData, getOddsData(parseLineValue(s), r, 2))
-		o.OddsData = append(o.OddsData, getOddsData(parseLineValue(s), r, 3))
-	} else {
-		for i := 1; i <= 3; i++ {
-			o.OddsData = append(o.OddsData, getOddsData(parseLineValue(s), r, i))
+		for i, c := range oddCells {
+			label := labels[i]
+			if label == "" {
+				label = o.Line
+			}
+			od := OddsData{LineValue: label}
+			odd, err := parseOdds(c, oddsInput)
+			if err != nil {
+				od.Missing = true
+				if err != errMissingOdds {
+					printLog(fmt.Sprintf("%s %s %s %s: %v, storing it as missing", market, scope, r.Bookmaker, label, err))
+				}
+			}
+			od.Odd = odd
+			if oddsFormat != "decimal" {
+				od.Formatted = formatOdds(odd, oddsFormat)
+			}
+			o.OddsData = append(o.OddsData, od)
 		}
+
+		o.Payout = calculatePayout(o.OddsData)
+		rows = append(rows, o)
 	}
 
-	o.Payout = calculatePayout(o.OddsData)
-	return o
+	return rows
 }
 
-func getOddsData(s string, r *RawOddRow, cell int) OddsData {
-	return OddsData{
-		LineValue: getLineValue(s, cell),
-		Odd:       getCellValue(r, cell),
-	}
+// normalizeScore formats a correct score line as home:away, e.g. '2 - 1' to '2:1'.
+func normalizeScore(s string) string {
+	return strings.NewReplacer(" ", "", "-", ":").Replace(s)
 }
 
-func isWithinTwoDays(date int64) bool {
-	matchDate := time.Unix(date, 0)
-
-	return time.Since(matchDate) <= 2*24*time.Hour
+// isWithinTwoDays reports whether the match starts today, later or on one of
+// the two previous days, counted in calendar days of -tz.
+func isWithinTwoDays(start time.Time) bool {
+	now := time.Now().In(matchTZ)
+	from := time.Date(now.Year(), now.Month(), now.Day()-2, 0, 0, 0, 0, matchTZ)
+	return !start.Before(from)
 }
 
 func filterMatches(matches []Match) []Match {
 	filteredMatches := []Match{}
 	for _, match := range matches {
-		if isWithinTwoDays(int64(match.DateStartTimestamp)) {
+		if isWithinTwoDays(matchStart(&match)) {
 			filteredMatches = append(filteredMatches, match)
 		}
 	}
 	return filteredMatches
 }
 
-// date is in format '1537317000' and target format is '2006-01-02 15:04:00'
-func parseMatchDate(date int64) string {
-	return time.Unix(date, 0).Format("2006-01-02 15:00")
+// matchStart is the start time of the match. DateStartTimestamp is the
+// actual start, DateStartBase the scheduled one which is used if the match
+// has no timestamp yet.
+func matchStart(m *Match) time.Time {
+	if m.DateStartTimestamp != 0 {
+		return time.Unix(int64(m.DateStartTimestamp), 0)
+	}
+	return time.Unix(int64(m.DateStartBase), 0)
 }
 
-func retroTeamId(name string) string {
-	switch name {
-	// NHL
-	case "Anaheim Ducks":
-		return "ANA"
-	case "Arizona Coyotes":
-		return "AZN"
-	case "Boston Bruins":
-		return "BOS"
-	case "Buffalo Sabres":
-		return "BUF"
-	case "Calgary Flames":
-		return "CGY"
-	case "Carolina Hurricanes":
-		return "CAR"
-	case "Chicago Blackhawks":
-		return "CHI"
-	case "Colorado Avalanche":
-		return "COL"
-	case "Columbus Blue Jackets":
-		return "CBJ"
-	case "Dallas Stars":
-		return "DAL"
-	case "Detroit Red Wings":
-		return "DET"
-	case "Edmonton Oilers":
-		return "EDM"
-	case "Florida Panthers":
-		return "FLA"
-	case "Los Angeles Kings":
-		return "LAK"
-	case "Minnesota Wild":
-		return "MIN"
-	case "Montreal Canadiens":
-		return "MTL"
-	case "Nashville Predators":
-		return "NSH"
-	case "New Jersey Devils":
-		return "NJD"
-	case "New York Islanders":
-		return "NYI"
-	case "New York Rangers":
-		return "NYR"
-	case "Ottawa Senators":
-		return "OTT"
-	case "Philadelphia Flyers":
-		return "PHI"
-	case "Pittsburgh Penguins":
-		return "PIT"
-	case "San Jose Sharks":
-		return "SJS"
-	case "Seattle Kraken":
-		return "SEA"
-	case "St. Louis Blues":
-		return "STL"
-	case "Tampa Bay Lightning":
-		return "TBL"
-	case "Toronto Maple Leafs":
-		return "TOR"
-	case "Vancouver Canucks":
-		return "VAN"
-	case "Vegas Golden Knights":
-		return "VGK"
-	case "Washington Capitals":
-		return "WSH"
-	case "Winnipeg Jets":
-		return "WPG"
-	case "Utah":
-		return "UTA"
-	// Default if not found
-	default:
-		return name
-	}
+// setMatchDates fills the start time of the match in -tz and the calendar
+// date it falls on both in -tz and in UTC, which differ for games near midnight.
+func setMatchDates(m *Match) {
+	start := matchStart(m)
+	m.Date = start.In(matchTZ).Format(time.RFC3339)
+	m.DateLocal = start.In(matchTZ).Format(time.DateOnly)
+	m.DateUTC = start.UTC().Format(time.DateOnly)
 }
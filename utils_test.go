@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestParseOddsTableKeepsEmptyCells(t *testing.T) {
+	tests := []struct {
+		name   string
+		suffix string
+		cells  []string
+		line   string
+		labels []string
+		odds   []float64
+	}{
+		{"over-under", "#over-under;2", []string{"5.5", "1.90", "", "94.1%"}, "5.5", []string{"1", "2"}, []float64{1.9, 0}},
+		{"1X2", "#1X2;2", []string{"2.10", "", "3.00", "95.0%"}, "1X2", []string{"1", "X", "2"}, []float64{2.1, 0, 3}},
+		{"ah", "#ah;2", []string{"-1.5", "", "1.85"}, "-1.5", []string{"1", "2"}, []float64{0, 1.85}},
+		{"eh", "#eh;2", []string{"-1", "3.40", "4.10", "1.80"}, "-1", []string{"1", "X", "2"}, []float64{3.4, 4.1, 1.8}},
+		{"cs", "#cs;2", []string{"2 - 1", "8.50"}, "2:1", []string{"2:1"}, []float64{8.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &OddsTable{Rows: []RawOddRow{{Bookmaker: "Pinnacle", Cells: tt.cells}}}
+			rows := parseOddsTable(table, tt.suffix, "FT", &GENERIC_SPORT)
+			if len(rows) != 1 {
+				t.Fatalf("got %d rows, want 1", len(rows))
+			}
+			r := rows[0]
+			if r.Line != tt.line {
+				t.Errorf("line %q, want %q", r.Line, tt.line)
+			}
+			if len(r.OddsData) != len(tt.labels) {
+				t.Fatalf("got %d odds, want %d", len(r.OddsData), len(tt.labels))
+			}
+			for i, od := range r.OddsData {
+				if od.LineValue != tt.labels[i] || od.Odd != tt.odds[i] || od.Missing != (tt.odds[i] == 0) {
+					t.Errorf("odd %d is %s %v (missing %v), want %s %v", i, od.LineValue, od.Odd, od.Missing, tt.labels[i], tt.odds[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseOddsTableSkipsEmptyRows(t *testing.T) {
+	table := &OddsTable{Rows: []RawOddRow{{Bookmaker: "Pinnacle", Cells: []string{"", "", "96.0%"}}}}
+	if rows := parseOddsTable(table, "#home-away;1", "FT", &GENERIC_SPORT); len(rows) != 0 {
+		t.Errorf("got %d rows for a row without odds, want 0", len(rows))
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// WideColumn is an odds column of the wide CSV, named
+// <source>_<market>_<outcome> or <source>_<market>_<line>_<outcome>, e.g.
+// 'pinnacle_1X2_1', 'max_OU-FT_5.5_over' or 'avg_AH-FT_-1.5_1'. The source is
+// a bookmaker id or one of WIDE_AGGREGATES across every bookmaker.
+type WideColumn struct {
+	Name    string
+	Source  string
+	Market  string
+	Line    string // Empty for markets without a line
+	Outcome string
+}
+
+// WIDE_AGGREGATES are the sources that combine the odds of every bookmaker
+var WIDE_AGGREGATES = []string{"max", "best", "avg", "median", "min", "count"}
+
+// WIDE_MATCH_COLUMNS are the match columns in front of the odds columns
+var WIDE_MATCH_COLUMNS = []string{
+	"OddsportalID", "URL", "HomeTeam", "AwayTeam", "TournamentName",
+	"Date", "LocalDate", "UTCDate", "Result", "HomeResult", "AwayResult", "Partialresult",
+}
+
+// WIDE_TRAILING_COLUMNS are match columns added later, they follow the odds
+// columns so the position of every earlier column stays the same
+var WIDE_TRAILING_COLUMNS = []string{"Season"}
+
+// DEFAULT_WIDE_COLUMNS are used without -columns
+var DEFAULT_WIDE_COLUMNS = []string{
+	"pinnacle_1X2_1", "pinnacle_1X2_X", "pinnacle_1X2_2",
+	"max_1X2_1", "max_1X2_X", "max_1X2_2",
+	"avg_1X2_1", "avg_1X2_X", "avg_1X2_2",
+	"pinnacle_ML_1", "pinnacle_ML_2",
+	"max_ML_1", "max_ML_2",
+	"avg_ML_1", "avg_ML_2",
+}
+
+// WIDE_OUTCOME_ALIASES map outcome names of a column to the stored labels of
+// the market, e.g. 'max_OU-FT_5.5_over' reads the '1' outcome
+var WIDE_OUTCOME_ALIASES = map[string]map[string]string{
+	"over-under": {"over": "1", "under": "2"},
+}
+
+// wideMarket returns the market suffix of an odds_data key, e.g.
+// 'over-under' for 'OU-P1', or "" for a market we don't know.
+func wideMarket(key string) string {
+	code, _, _ := strings.Cut(strings.ToUpper(key), "-")
+	if code == "ML" {
+		return "home-away"
+	}
+	for suffix, c := range MARKET_CODES {
+		if c == code {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// parseWideColumn parses a column name, the market keys contain '-' but never
+// '_'. The outcome of a known market has to be one it can have, so a typo
+// doesn't give an empty column.
+func parseWideColumn(name string) (WideColumn, error) {
+	parts := strings.Split(name, "_")
+	c := WideColumn{Name: name, Source: strings.ToLower(parts[0])}
+	switch len(parts) {
+	case 3:
+		c.Market, c.Outcome = parts[1], parts[2]
+	case 4:
+		c.Market, c.Line, c.Outcome = parts[1], parts[2], parts[3]
+	default:
+		return c, fmt.Errorf("invalid column %q, expected source_market_outcome or source_market_line_outcome", name)
+	}
+	if c.Source == "" || c.Market == "" || c.Outcome == "" {
+		return c, fmt.Errorf("invalid column %q", name)
+	}
+
+	market := wideMarket(c.Market)
+	labels, ok := MARKET_OUTCOMES[market]
+	if !ok {
+		return c, nil
+	}
+	if alias, ok := WIDE_OUTCOME_ALIASES[market][strings.ToLower(c.Outcome)]; ok {
+		c.Outcome = alias
+	}
+	switch market {
+	case "cs":
+		if h, a, ok := strings.Cut(c.Outcome, ":"); !ok || h == "" || a == "" {
+			return c, fmt.Errorf("invalid column %q, the outcome of %s is a score like 2:1", name, c.Market)
+		}
+	default:
+		if !slices.ContainsFunc(labels, func(l string) bool { return strings.EqualFold(l, c.Outcome) }) {
+			return c, fmt.Errorf("invalid column %q, %s has no outcome %q, use one of %v", name, c.Market, c.Outcome, labels)
+		}
+	}
+	if c.Line == "" && slices.Contains([]string{"over-under", "ah", "eh"}, market) {
+		return c, fmt.Errorf("invalid column %q, %s needs a line, e.g. %s_%s_1.5_%s", name, c.Market, c.Source, c.Market, c.Outcome)
+	}
+	return c, nil
+}
+
+// loadWideColumns reads the column spec, one column per line, '#' starts a
+// comment. An empty path gives DEFAULT_WIDE_COLUMNS.
+func loadWideColumns(path string) ([]WideColumn, error) {
+	names := DEFAULT_WIDE_COLUMNS
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		names = nil
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			if line = strings.TrimSpace(line); line != "" {
+				names = append(names, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var columns []WideColumn
+	for _, n := range names {
+		c, err := parseWideColumn(n)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns in %s", path)
+	}
+	return columns, nil
+}
+
+// sameLine compares lines numerically when both are numbers, so '5.5' is
+// the same line as '5.50' and '+1.5' the same as '1.5'.
+func sameLine(a string, b string) bool {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return fa == fb
+	}
+	return strings.EqualFold(a, b)
+}
+
+// hasLine reports whether the row is one line of a line market. Files
+// scraped before lineUnit existed only have the numeric line.
+func hasLine(row *OddRow) bool {
+	if row.LineUnit != "" {
+		return true
+	}
+	_, err := strconv.ParseFloat(row.Line, 64)
+	return err == nil
+}
+
+// odds returns the decimal odds of the column's outcome from every wanted
+// bookmaker of the match.
+func (c *WideColumn) odds(m *Match) []float64 {
+	var odds []float64
+	for _, row := range m.OddsData[c.Market] {
+		if !slices.Contains(WIDE_AGGREGATES, c.Source) && row.BookmakerID != c.Source {
+			continue
+		}
+		if c.Line != "" && !sameLine(row.Line, c.Line) {
+			continue
+		}
+
+		for _, od := range row.OddsData {
+			if od.Missing || od.Odd <= 1 || !strings.EqualFold(od.LineValue, c.Outcome) {
+				continue
+			}
+			// Without a line in the column, only markets without lines or
+			// outcomes named after their line (correct score) match
+			if c.Line == "" && hasLine(&row) && !sameLine(row.Line, od.LineValue) {
+				continue
+			}
+			odds = append(odds, od.Odd)
+		}
+	}
+	return odds
+}
+
+// value is the column's cell for the match, empty if no bookmaker has odds for it.
+func (c *WideColumn) value(m *Match) string {
+	odds := c.odds(m)
+	if c.Source == "count" {
+		return strconv.Itoa(len(odds))
+	}
+	if len(odds) == 0 {
+		return ""
+	}
+
+	slices.Sort(odds)
+	var v float64
+	switch c.Source {
+	case "min":
+		v = odds[0]
+	case "avg":
+		for _, o := range odds {
+			v += o
+		}
+		v = math.Round(v/float64(len(odds))*10000) / 10000
+	case "median":
+		n := len(odds)
+		v = odds[n/2]
+		if n%2 == 0 {
+			v = math.Round((odds[n/2-1]+odds[n/2])/2*10000) / 10000
+		}
+	default:
+		// max, best and a single bookmaker's (last) odds
+		v = odds[len(odds)-1]
+	}
+	return formatOdds(v, oddsFormat)
+}
+
+// processWideCSVFile writes one row per match with the -columns odds columns.
+func processWideCSVFile(matches []Match) error {
+	columns, err := loadWideColumns(columnSpec)
+	if err != nil {
+		return fmt.Errorf("error loading columns: %w", err)
+	}
+
+	file, err := createAtomic(outputFileName(".csv"), 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	header := slices.Clone(WIDE_MATCH_COLUMNS)
+	for _, c := range columns {
+		header = append(header, c.Name)
+	}
+	header = append(header, WIDE_TRAILING_COLUMNS...)
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+
+	for _, match := range matches {
+		prepareMatch(&match)
+
+		record := []string{
+			fmt.Sprint(match.ID),
+			match.URL,
+			match.HomeAbbreviation,
+			match.AwayAbbreviation,
+			match.TournamentName,
+			match.Date,
+			match.DateLocal,
+			match.DateUTC,
+			match.Result,
+			match.HomeResult,
+			match.AwayResult,
+			match.Partialresult,
+		}
+		for _, c := range columns {
+			record = append(record, c.value(&match))
+		}
+		record = append(record, match.Season)
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("error writing record: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	if err := file.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Compiled %v rows of games \n", len(matches))
+	fmt.Println("Wrote wide CSV File")
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import "testing"
+
+func TestParseWideColumn(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    WideColumn
+		wantErr bool
+	}{
+		{name: "pinnacle_1X2_1", want: WideColumn{Source: "pinnacle", Market: "1X2", Outcome: "1"}},
+		{name: "MAX_OU-FT_5.5_1", want: WideColumn{Source: "max", Market: "OU-FT", Line: "5.5", Outcome: "1"}},
+		{name: "avg_AH-FT_-1.5_2", want: WideColumn{Source: "avg", Market: "AH-FT", Line: "-1.5", Outcome: "2"}},
+		{name: "median_CS_2:1", want: WideColumn{Source: "median", Market: "CS", Outcome: "2:1"}},
+		{name: "max_OU-FT_5.5_Over", want: WideColumn{Source: "max", Market: "OU-FT", Line: "5.5", Outcome: "1"}},
+		{name: "max_OU-P1_1.5_under", want: WideColumn{Source: "max", Market: "OU-P1", Line: "1.5", Outcome: "2"}},
+		{name: "avg_BTTS_yes", want: WideColumn{Source: "avg", Market: "BTTS", Outcome: "yes"}},
+		{name: "avg_OE-FT_EVEN", want: WideColumn{Source: "avg", Market: "OE-FT", Outcome: "EVEN"}},
+		{name: "max_TEAM-TOTALS-FT_5.5_Over", want: WideColumn{Source: "max", Market: "TEAM-TOTALS-FT", Line: "5.5", Outcome: "Over"}},
+		{name: "pinnacle_1X2_3", wantErr: true},
+		{name: "pinnacle_ML_X", wantErr: true},
+		{name: "max_OU-FT_5.5_3", wantErr: true},
+		{name: "max_OU-FT_over", wantErr: true},
+		{name: "max_AH-FT_1", wantErr: true},
+		{name: "median_CS_21", wantErr: true},
+		{name: "pinnacle_1X2", wantErr: true},
+		{name: "a_b_c_d_e", wantErr: true},
+		{name: "_1X2_1", wantErr: true},
+		{name: "max__1", wantErr: true},
+		{name: "max_OU-FT_5.5_", wantErr: true},
+	}
+	for _, tt := range tests {
+		c, err := parseWideColumn(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseWideColumn(%q) = %+v, want an error", tt.name, c)
+			}
+			continue
+		}
+		tt.want.Name = tt.name
+		if err != nil || c != tt.want {
+			t.Errorf("parseWideColumn(%q) = %+v, %v, want %+v", tt.name, c, err, tt.want)
+		}
+	}
+}
+
+func TestSameLine(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"5.5", "5.50", true},
+		{"+1.5", "1.5", true},
+		{"-1.5", "1.5", false},
+		{"0", "-0", true},
+		{"2:1", "2:1", true},
+		{"Over", "over", true},
+		{"2:1", "1:2", false},
+		{"5.5", "", false},
+	}
+	for _, tt := range tests {
+		if got := sameLine(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameLine(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestWideColumnValue(t *testing.T) {
+	oddsFormat = "decimal"
+	m := &Match{OddsData: map[string][]OddRow{
+		"1X2": {
+			{BookmakerID: "pinnacle", OddsData: []OddsData{{LineValue: "1", Odd: 2.1}, {LineValue: "X", Odd: 3.4}}},
+			{BookmakerID: "bet365", OddsData: []OddsData{{LineValue: "1", Odd: 2}, {LineValue: "X", Odd: 0, Missing: true}}},
+			{BookmakerID: "unibet", OddsData: []OddsData{{LineValue: "1", Odd: 2.3}}},
+		},
+		"OU-FT": {
+			{BookmakerID: "pinnacle", Line: "5.5", LineUnit: "goals", OddsData: []OddsData{{LineValue: "1", Odd: 1.9}}},
+			{BookmakerID: "pinnacle", Line: "6.5", LineUnit: "goals", OddsData: []OddsData{{LineValue: "1", Odd: 2.6}}},
+		},
+		"CS": {
+			{BookmakerID: "pinnacle", Line: "2:1", LineUnit: "goals", OddsData: []OddsData{{LineValue: "2:1", Odd: 8.5}}},
+		},
+	}}
+
+	tests := map[string]string{
+		"pinnacle_1X2_1":        "2.1",
+		"max_1X2_1":             "2.3",
+		"min_1X2_1":             "2",
+		"avg_1X2_1":             "2.1333",
+		"median_1X2_1":          "2.1",
+		"count_1X2_1":           "3",
+		"count_1X2_X":           "1", // missing odds are not counted
+		"max_1X2_2":             "",
+		"pinnacle_OU-FT_5.50_1": "1.9",
+		"median_CS_2:1":         "8.5",
+	}
+	for name, want := range tests {
+		c, err := parseWideColumn(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.value(m); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// XLSX_MATCH_COLUMNS are the columns of the Matches sheet
+var XLSX_MATCH_COLUMNS = []string{
+	"OddsportalID", "URL", "HomeTeam", "AwayTeam", "HomeCanonical", "AwayCanonical",
+	"TournamentName", "EventStageName", "Date", "LocalDate", "UTCDate",
+	"Result", "HomeResult", "AwayResult", "Partialresult", "BookmakersCount", "Season", "Source",
+}
+
+// XLSX_MARKET_COLUMNS are the columns of every market sheet
+var XLSX_MARKET_COLUMNS = []string{
+	"OddsportalID", "LocalDate", "HomeTeam", "AwayTeam", "Scope", "Bookmaker", "BookmakerID",
+	"Line", "LineUnit", "LineValue", "Odd", "Payout", "OpeningOdd", "OpeningOddDate", "Season",
+}
+
+// sheetName makes a market key a valid sheet name, at most 31 characters
+// without any of []:*?/\. Excel compares sheet names case-insensitively, a
+// name already in used gets a numeric suffix, e.g. 'OU-FT (2)'.
+func sheetName(key string, used map[string]bool) string {
+	base := []rune(strings.NewReplacer("[", "(", "]", ")", ":", "-", "*", "-", "?", "", "/", "-", "\\", "-").Replace(key))
+	if len(base) == 0 {
+		base = []rune("Market")
+	}
+	name := string(base[:min(len(base), 31)])
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		name = string(base[:min(len(base), 31-len(suffix))]) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// writeSheet streams the rows into a sheet with a frozen header. The rows are
+// an Excel table, which gives the header its autofilter, table names must be
+// unique in the workbook.
+func writeSheet(f *excelize.File, sheet string, table string, header []string, rows [][]interface{}) error {
+	if len(rows)+1 > excelize.TotalRows {
+		return fmt.Errorf("%d rows don't fit in a sheet, use -format parquet or csv", len(rows))
+	}
+
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+	err = sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(header))
+	for i, h := range header {
+		values[i] = h
+	}
+	if err := sw.SetRow("A1", values); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := sw.SetRow(cell, row); err != nil {
+			return err
+		}
+	}
+
+	// AutoFilter doesn't work on streamed sheets, a table has its own
+	last, _ := excelize.CoordinatesToCellName(len(header), len(rows)+1)
+	noStripes := false
+	err = sw.AddTable(&excelize.Table{
+		Range:          "A1:" + last,
+		Name:           table,
+		StyleName:      "TableStyleLight1",
+		ShowRowStripes: &noStripes,
+	})
+	if err != nil {
+		return err
+	}
+	return sw.Flush()
+}
+
+// optionalFloat leaves the cell empty for missing values instead of writing 0.
+func optionalFloat(v float64, ok bool) interface{} {
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+// processXLSXFile writes a workbook with a Matches sheet and one sheet per
+// market with every bookmaker's odds.
+func processXLSXFile(matches []Match) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName("Sheet1", "Matches"); err != nil {
+		return err
+	}
+
+	var matchRows [][]interface{}
+	marketRows := make(map[string][][]interface{})
+	for _, match := range matches {
+		prepareMatch(&match)
+
+		matchRows = append(matchRows, []interface{}{
+			match.ID, match.URL, match.HomeAbbreviation, match.AwayAbbreviation,
+			match.HomeCanonicalName, match.AwayCanonicalName,
+			match.TournamentName, match.EventStageName, match.Date, match.DateLocal, match.DateUTC,
+			match.Result, match.HomeResult, match.AwayResult, match.Partialresult, match.BookmakersCount,
+			match.Season, match.Source,
+		})
+
+		for market, marketData := range match.OddsData {
+			for _, lineData := range marketData {
+				for _, odd := range lineData.OddsData {
+					marketRows[market] = append(marketRows[market], []interface{}{
+						match.ID, match.DateLocal, match.HomeAbbreviation, match.AwayAbbreviation,
+						lineData.Scope, lineData.Bookmaker, lineData.BookmakerID,
+						lineData.Line, lineData.LineUnit, odd.LineValue,
+						optionalFloat(odd.Odd, !odd.Missing && odd.Odd > 0),
+						optionalFloat(lineData.Payout, lineData.Payout != 0),
+						optionalFloat(odd.OpeningOdd.Odds, odd.OpeningOdd.Odds > 0),
+						odd.OpeningOdd.Date,
+						match.Season,
+					})
+				}
+			}
+		}
+	}
+
+	if err := writeSheet(f, "Matches", "Matches", XLSX_MATCH_COLUMNS, matchRows); err != nil {
+		return fmt.Errorf("error writing Matches sheet: %w", err)
+	}
+
+	markets := make([]string, 0, len(marketRows))
+	for m := range marketRows {
+		markets = append(markets, m)
+	}
+	sort.Strings(markets)
+
+	used := map[string]bool{"matches": true}
+	for i, market := range markets {
+		sheet := sheetName(market, used)
+		if _, err := f.NewSheet(sheet); err != nil {
+			return fmt.Errorf("error adding sheet %s: %w", sheet, err)
+		}
+		table := fmt.Sprintf("Market%d", i+1)
+		if err := writeSheet(f, sheet, table, XLSX_MARKET_COLUMNS, marketRows[market]); err != nil {
+			return fmt.Errorf("error writing sheet %s: %w", sheet, err)
+		}
+	}
+
+	file, err := createAtomic(outputFileName(".xlsx"), 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := f.Write(file); err != nil {
+		return fmt.Errorf("error saving workbook: %w", err)
+	}
+	if err := file.Commit(); err != nil {
+		return fmt.Errorf("error saving workbook: %w", err)
+	}
+
+	fmt.Printf("Compiled %v games into %v market sheets\n", len(matches), len(markets))
+	fmt.Println("Wrote XLSX File")
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSheetNameUnique(t *testing.T) {
+	long := "TEAM-TOTALS-HOME-OVER-UNDER-1ST-PERIOD"
+	keys := []string{"OU-FT", "ou-ft", "Matches", long + "-A", long + "-B", "AH/FT", "AH:FT", "?"}
+	want := []string{"OU-FT", "ou-ft (2)", "Matches (2)", long[:31], long[:27] + " (2)", "AH-FT", "AH-FT (2)", "Market"}
+
+	used := map[string]bool{"matches": true}
+	seen := make(map[string]bool)
+	for i, key := range keys {
+		got := sheetName(key, used)
+		if got != want[i] {
+			t.Errorf("sheetName(%q) = %q, want %q", key, got, want[i])
+		}
+		if utf8.RuneCountInString(got) > 31 {
+			t.Errorf("sheetName(%q) = %q is longer than 31 characters", key, got)
+		}
+		if seen[strings.ToLower(got)] {
+			t.Errorf("sheetName(%q) = %q is not unique", key, got)
+		}
+		seen[strings.ToLower(got)] = true
+	}
+}